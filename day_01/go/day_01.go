@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"sort"
 	"strconv"
 )
 
@@ -43,19 +44,83 @@ func part1(values []int) {
 	fmt.Println("Answer part 1: ", v)
 }
 
-func part2(values []int) {
+// firstRepeat finds the first frequency reached twice without simulating the
+// device. After the first pass every frequency is a prefix sum shifted by a
+// multiple of the net drift, so two prefix sums can only ever meet if they
+// share a residue modulo the drift. passes counts the full passes over the
+// changes completed before the repeat. ok is false if nothing ever repeats.
+func firstRepeat(values []int) (frequency int, passes int, ok bool) {
+	n := len(values)
+	if n == 0 {
+		return 0, 0, false
+	}
+
+	sums := make([]int, n)
+	drift := 0
+	for i, value := range values {
+		sums[i] = drift
+		drift += value
+	}
+
+	// A repeat within the first pass always comes before any later one.
 	visited := map[int]bool{0: true}
-	v := 0
-	for {
-		for _, value := range values {
-			v += value
-			if _, ok := visited[v]; ok {
-				fmt.Println("Answer part 2: ", v)
-				return
-			} else {
-				visited[v] = true
-			}
+	for k := 1; k < n; k++ {
+		if visited[sums[k]] {
+			return sums[k], 0, true
+		}
+		visited[sums[k]] = true
+	}
+	if drift == 0 {
+		return 0, 0, true
+	}
+
+	// Work with a positive drift, so every sum moves upwards each pass.
+	sign := 1
+	if drift < 0 {
+		sign = -1
+	}
+	step := drift * sign
+	residue := func(i int) int {
+		return ((sums[i]*sign)%step + step) % step
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		ra, rb := residue(order[a]), residue(order[b])
+		if ra != rb {
+			return ra < rb
+		}
+		return sums[order[a]]*sign < sums[order[b]]*sign
+	})
+
+	// Within a residue class, sum i first hits the next larger sum j after
+	// (sums[j]-sums[i])/drift passes; sum j was already visited in pass 0.
+	best := -1
+	for a := 0; a+1 < n; a++ {
+		i, j := order[a], order[a+1]
+		if residue(i) != residue(j) {
+			continue
 		}
+		k := i + (sums[j]-sums[i])*sign/step*n
+		if best < 0 || k < best {
+			best = k
+			frequency = sums[j]
+		}
+	}
+	if best < 0 {
+		return 0, 0, false
+	}
+	return frequency, (best - 1) / n, true
+}
+
+func part2(values []int) {
+	if frequency, passes, ok := firstRepeat(values); ok {
+		fmt.Printf("Answer part 2:  %v (after %v full passes)\n", frequency, passes)
+	} else {
+		fmt.Println("Answer part 2:  never repeats")
 	}
 }
 
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestFirstRepeat(t *testing.T) {
+	cases := []struct {
+		values    []int
+		frequency int
+		ok        bool
+	}{
+		{[]int{1, -2, 3, 1}, 2, true},
+		{[]int{1, -1}, 0, true},
+		{[]int{3, 3, 4, -2, -4}, 10, true},
+		{[]int{-6, 3, 8, 5, -6}, 5, true},
+		{[]int{7, 7, -2, -7, -4}, 14, true},
+		{[]int{-7, -7, 2, 7, 4}, -14, true},
+		{[]int{1, 1}, 0, false},
+		{[]int{}, 0, false},
+	}
+	for _, c := range cases {
+		frequency, _, ok := firstRepeat(c.values)
+		if ok != c.ok || frequency != c.frequency {
+			t.Errorf("%v: expected %v (%v), got %v (%v).", c.values, c.frequency, c.ok, frequency, ok)
+		}
+	}
+}
+
+func TestFirstRepeatMatchesSimulation(t *testing.T) {
+	values := []int{13, -4, 9, -21, 6, -2, 8, -7, 1}
+	visited := map[int]bool{0: true}
+	v, k := 0, 0
+	for {
+		v += values[k%len(values)]
+		k++
+		if visited[v] {
+			break
+		}
+		visited[v] = true
+	}
+	frequency, passes, ok := firstRepeat(values)
+	if !ok || frequency != v || passes != (k-1)/len(values) {
+		t.Errorf("Expected %v after %v passes, got %v after %v passes.", v, (k-1)/len(values), frequency, passes)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"sort"
 	"strings"
 )
 
@@ -32,50 +33,78 @@ func loadFile(filename string) []string {
 	}
 }
 
-func countPairsAndTriplets(s string) (int, int) {
+// letterMultiplicities counts, for each multiplicity n, how many distinct
+// letters occur exactly n times in s.
+func letterMultiplicities(s string) map[int]int {
 	count := map[rune]int{}
-	runes := []rune(s)
-	for _, r := range runes {
-		if v, ok := count[r]; ok {
-			count[r] = v + 1
-		} else {
-			count[r] = 1
-		}
+	for _, r := range s {
+		count[r]++
 	}
 
-	pairs := 0
-	triplets := 0
+	multiplicities := map[int]int{}
 	for _, v := range count {
-		switch v {
-		case 2:
-			pairs++
-		case 3:
-			triplets++
-		}
+		multiplicities[v]++
 	}
-	return pairs, triplets
+	return multiplicities
 }
 
-func part1(serials []string) {
-	pairs := 0
-	triplets := 0
+func countPairsAndTriplets(s string) (int, int) {
+	multiplicities := letterMultiplicities(s)
+	return multiplicities[2], multiplicities[3]
+}
+
+// histogram counts, for each multiplicity, how many serials have at least one
+// letter occurring exactly that many times.
+func histogram(serials []string) map[int]int {
+	h := map[int]int{}
 	for _, serial := range serials {
-		p, t := countPairsAndTriplets(serial)
-		if p >= 1 {
-			pairs++
-		}
-		if t >= 1 {
-			triplets++
+		for n := range letterMultiplicities(serial) {
+			h[n]++
 		}
 	}
-	checksum := pairs * triplets
-	fmt.Printf("Part1: %v * %v = %v\n", pairs, triplets, checksum)
+	return h
+}
+
+// checksum generalises the puzzle checksum to any set of multiplicities. It
+// returns the number of serials having a letter of each multiplicity and the
+// product of those counts.
+func checksum(serials []string, multiplicities []int) (map[int]int, int) {
+	h := histogram(serials)
+	counts := map[int]int{}
+	product := 1
+	for _, n := range multiplicities {
+		counts[n] = h[n]
+		product *= h[n]
+	}
+	return counts, product
+}
+
+func printHistogram(h map[int]int) {
+	multiplicities := make([]int, 0, len(h))
+	for n := range h {
+		multiplicities = append(multiplicities, n)
+	}
+	sort.Ints(multiplicities)
+
+	fmt.Println("Multiplicity  Serials")
+	for _, n := range multiplicities {
+		fmt.Printf("%12d  %7d\n", n, h[n])
+	}
+}
+
+func part1(serials []string) {
+	counts, product := checksum(serials, []int{2, 3})
+	fmt.Printf("Part1: %v * %v = %v\n", counts[2], counts[3], product)
+	printHistogram(histogram(serials))
 }
 
 func numberOfNonEqualCharacters(s1 string, s2 string) (int, string) {
 	r1 := []rune(s1)
 	r2 := []rune(s2)
-	count := 0
+	if len(r1) > len(r2) {
+		r1, r2 = r2, r1
+	}
+	count := len(r2) - len(r1)
 	rest := make([]rune, 0)
 	for i := 0; i < len(r1); i++ {
 		if r1[i] != r2[i] {
@@ -87,18 +116,164 @@ func numberOfNonEqualCharacters(s1 string, s2 string) (int, string) {
 	return count, string(rest)
 }
 
-func part2(serials []string) {
-	for i, serial1 := range serials {
-		for _, serial2 := range serials[i:] {
-			if serial1 == serial2 {
-				continue
+type Metric int
+
+const (
+	Hamming Metric = iota
+	Levenshtein
+)
+
+// Match is a pair of serials, given by index, within the requested distance.
+type Match struct {
+	I, J     int
+	Distance int
+	Common   string
+}
+
+// deleted marks a removed position in a Hamming key, so that keys from
+// different positions never collide.
+const deleted = '\x00'
+
+// deletionNeighbourhood returns the keys under which a serial is indexed. Two
+// serials within distance k always share at least one key. For Hamming every
+// key removes exactly k positions in place; for Levenshtein the keys are all
+// strings reachable by removing up to k runes.
+func deletionNeighbourhood(serial string, k int, metric Metric) []string {
+	runes := []rune(serial)
+	keys := map[string]bool{}
+	var remove func(r []rune, from int, left int)
+	remove = func(r []rune, from int, left int) {
+		if metric == Levenshtein || left == 0 {
+			keys[string(r)] = true
+		}
+		if left == 0 {
+			return
+		}
+		for i := from; i < len(r); i++ {
+			next := make([]rune, 0, len(r))
+			if metric == Hamming {
+				next = append(next, r...)
+				next[i] = deleted
+				remove(next, i+1, left-1)
+			} else {
+				next = append(append(next, r[:i]...), r[i+1:]...)
+				remove(next, i, left-1)
+			}
+		}
+	}
+	if metric == Hamming && k > len(runes) {
+		k = len(runes)
+	}
+	remove(runes, 0, k)
+
+	result := make([]string, 0, len(keys))
+	for key := range keys {
+		result = append(result, key)
+	}
+	return result
+}
+
+// alignment returns the edit distance between two serials together with the
+// runes kept unchanged by one optimal alignment.
+func alignment(s1 string, s2 string) (int, string) {
+	r1 := []rune(s1)
+	r2 := []rune(s2)
+	w := len(r2) + 1
+	d := make([]int, (len(r1)+1)*w)
+	for i := 0; i <= len(r1); i++ {
+		d[i*w] = i
+	}
+	for j := 0; j <= len(r2); j++ {
+		d[j] = j
+	}
+	for i := 1; i <= len(r1); i++ {
+		for j := 1; j <= len(r2); j++ {
+			cost := 1
+			if r1[i-1] == r2[j-1] {
+				cost = 0
+			}
+			best := d[(i-1)*w+j-1] + cost
+			if v := d[(i-1)*w+j] + 1; v < best {
+				best = v
 			}
-			count, rest := numberOfNonEqualCharacters(serial1, serial2)
-			if count == 1 {
-				fmt.Println(count, rest)
+			if v := d[i*w+j-1] + 1; v < best {
+				best = v
 			}
+			d[i*w+j] = best
 		}
 	}
+
+	common := make([]rune, 0)
+	for i, j := len(r1), len(r2); i > 0 && j > 0; {
+		switch {
+		case r1[i-1] == r2[j-1] && d[i*w+j] == d[(i-1)*w+j-1]:
+			common = append(common, r1[i-1])
+			i--
+			j--
+		case d[i*w+j] == d[(i-1)*w+j-1]+1:
+			i--
+			j--
+		case d[i*w+j] == d[(i-1)*w+j]+1:
+			i--
+		default:
+			j--
+		}
+	}
+	for a, b := 0, len(common)-1; a < b; a, b = a+1, b-1 {
+		common[a], common[b] = common[b], common[a]
+	}
+	return d[len(d)-1], string(common)
+}
+
+// findNearDuplicates returns every pair of serials within distance k of each
+// other. Serials are indexed by their deletion neighbourhood so only pairs
+// sharing a key are compared, instead of every pair.
+func findNearDuplicates(serials []string, k int, metric Metric) []Match {
+	index := map[string][]int{}
+	for i, serial := range serials {
+		for _, key := range deletionNeighbourhood(serial, k, metric) {
+			index[key] = append(index[key], i)
+		}
+	}
+
+	seen := map[[2]int]bool{}
+	matches := make([]Match, 0)
+	for _, ids := range index {
+		for a := 0; a < len(ids); a++ {
+			for b := a + 1; b < len(ids); b++ {
+				pair := [2]int{ids[a], ids[b]}
+				if seen[pair] || serials[pair[0]] == serials[pair[1]] {
+					continue
+				}
+				seen[pair] = true
+
+				var distance int
+				var common string
+				if metric == Hamming {
+					distance, common = numberOfNonEqualCharacters(serials[pair[0]], serials[pair[1]])
+				} else {
+					distance, common = alignment(serials[pair[0]], serials[pair[1]])
+				}
+				if distance <= k {
+					matches = append(matches, Match{pair[0], pair[1], distance, common})
+				}
+			}
+		}
+	}
+
+	sort.Slice(matches, func(a, b int) bool {
+		if matches[a].I != matches[b].I {
+			return matches[a].I < matches[b].I
+		}
+		return matches[a].J < matches[b].J
+	})
+	return matches
+}
+
+func part2(serials []string) {
+	for _, match := range findNearDuplicates(serials, 1, Hamming) {
+		fmt.Printf("Part2: %v %v -> %v\n", serials[match.I], serials[match.J], match.Common)
+	}
 }
 
 func main() {
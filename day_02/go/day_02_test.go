@@ -19,3 +19,59 @@ func TestNumberOfNonEqualCharacters(t *testing.T) {
 		t.Fatalf("Expected fgij, got %v", rest)
 	}
 }
+
+func TestFindNearDuplicatesHamming(t *testing.T) {
+	serials := []string{"abcde", "fghij", "klmno", "pqrst", "fguij", "axcye", "wcxyz"}
+
+	matches := findNearDuplicates(serials, 1, Hamming)
+	if len(matches) != 1 {
+		t.Fatalf("Expected 1 match, got %v.", matches)
+	} else if m := matches[0]; m.I != 1 || m.J != 4 || m.Common != "fgij" {
+		t.Fatalf("Expected fghij/fguij -> fgij, got %v.", m)
+	}
+
+	matches = findNearDuplicates(serials, 2, Hamming)
+	if len(matches) != 2 {
+		t.Fatalf("Expected 2 matches, got %v.", matches)
+	} else if m := matches[0]; m.I != 0 || m.J != 5 || m.Distance != 2 || m.Common != "ace" {
+		t.Fatalf("Expected abcde/axcye -> ace, got %v.", m)
+	}
+}
+
+func TestFindNearDuplicatesLevenshtein(t *testing.T) {
+	serials := []string{"abcde", "abde", "xabcde", "fghij", "abxde"}
+
+	matches := findNearDuplicates(serials, 1, Levenshtein)
+	expected := []Match{
+		{0, 1, 1, "abde"},
+		{0, 2, 1, "abcde"},
+		{0, 4, 1, "abde"},
+		{1, 4, 1, "abde"},
+	}
+	if len(matches) != len(expected) {
+		t.Fatalf("Expected %v, got %v.", expected, matches)
+	}
+	for i := range expected {
+		if matches[i] != expected[i] {
+			t.Errorf("Expected %v, got %v.", expected[i], matches[i])
+		}
+	}
+
+	if matches := findNearDuplicates(serials, 1, Hamming); len(matches) != 1 {
+		t.Errorf("Expected only abcde/abxde within Hamming distance 1, got %v.", matches)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	serials := []string{"abcdef", "bababc", "abbcde", "abcccd", "aabcdd", "abcdee", "ababab"}
+
+	counts, product := checksum(serials, []int{2, 3})
+	if counts[2] != 4 || counts[3] != 3 || product != 12 {
+		t.Fatalf("Expected 4 * 3 = 12, got %v = %v.", counts, product)
+	}
+
+	counts, product = checksum(serials, []int{1, 2, 3, 4})
+	if counts[1] != 6 || counts[4] != 0 || product != 0 {
+		t.Fatalf("Expected 6 * 4 * 3 * 0 = 0, got %v = %v.", counts, product)
+	}
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"bufio"
+	"encoding/csv"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -20,16 +23,361 @@ type Cut struct {
 }
 
 func (c1 *Cut) Overlaps(c2 *Cut) bool {
-	// Check if not overlapping.
-	if c1.x+c1.w < c2.x || c1.x > c2.x+c2.w {
+	// Check if not overlapping. Cuts cover [x, x+w) and [y, y+h), so cuts
+	// that only touch along an edge do not share any square inch.
+	if c1.x+c1.w <= c2.x || c1.x >= c2.x+c2.w {
 		return false
 	}
-	if c1.y+c1.h < c2.y || c1.y > c2.y+c2.h {
+	if c1.y+c1.h <= c2.y || c1.y >= c2.y+c2.h {
 		return false
 	}
 	return true
 }
 
+// Fabric answers area questions about a set of cuts by sweeping a vertical
+// line across the x edges of the cuts, so coordinates are not bounded by any
+// fixed fabric size.
+type Fabric struct {
+	cuts []*Cut
+	xs   []int
+	ys   []int
+}
+
+func NewFabric(cuts []*Cut) *Fabric {
+	xs := make([]int, 0, 2*len(cuts))
+	ys := make([]int, 0, 2*len(cuts))
+	for _, c := range cuts {
+		xs = append(xs, c.x, c.x+c.w)
+		ys = append(ys, c.y, c.y+c.h)
+	}
+	return &Fabric{cuts, uniqueSorted(xs), uniqueSorted(ys)}
+}
+
+func uniqueSorted(values []int) []int {
+	sort.Ints(values)
+	unique := values[:0]
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// byLeftEdge returns the cuts with a non-empty area ordered by their left
+// edge.
+func (f *Fabric) byLeftEdge() []*Cut {
+	order := make([]*Cut, 0, len(f.cuts))
+	for _, c := range f.cuts {
+		if c.w > 0 && c.h > 0 {
+			order = append(order, c)
+		}
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i].x < order[j].x })
+	return order
+}
+
+// stillOpen drops the cuts that end at or before x.
+func stillOpen(active []*Cut, x int) []*Cut {
+	open := active[:0]
+	for _, a := range active {
+		if a.x+a.w > x {
+			open = append(open, a)
+		}
+	}
+	return open
+}
+
+// coverTree is a segment tree over the intervals between consecutive y
+// coordinates, counting the cuts that cover each interval. A node's cover is
+// the number of cuts spanning all of it that were not passed on to its
+// children, and length[j] is how much of it is covered at least j times, for
+// j up to k. Adding or removing a cut then touches O(log n) nodes.
+type coverTree struct {
+	ys     []int
+	k      int
+	cover  []int
+	length [][]int
+}
+
+func newCoverTree(ys []int, k int) *coverTree {
+	nodes := 4 * len(ys)
+	t := &coverTree{ys, k, make([]int, nodes), make([][]int, nodes)}
+	for i := range t.length {
+		t.length[i] = make([]int, k+1)
+	}
+	return t
+}
+
+// Add adds delta to the cover of the y range [y0, y1), given as indexes
+// into ys.
+func (t *coverTree) Add(y0, y1, delta int) {
+	t.add(1, 0, len(t.ys)-1, y0, y1, delta)
+}
+
+// Covered returns the length covered at least k times.
+func (t *coverTree) Covered() int {
+	return t.length[1][t.k]
+}
+
+func (t *coverTree) add(node, lo, hi, y0, y1, delta int) {
+	if y1 <= lo || hi <= y0 {
+		return
+	}
+	if y0 <= lo && hi <= y1 {
+		t.cover[node] += delta
+	} else {
+		mid := (lo + hi) / 2
+		t.add(2*node, lo, mid, y0, y1, delta)
+		t.add(2*node+1, mid, hi, y0, y1, delta)
+	}
+
+	full := t.ys[hi] - t.ys[lo]
+	c := t.cover[node]
+	for j := range t.length[node] {
+		switch {
+		case j <= c:
+			t.length[node][j] = full
+		case hi-lo == 1:
+			t.length[node][j] = 0
+		default:
+			t.length[node][j] = t.length[2*node][j-c] + t.length[2*node+1][j-c]
+		}
+	}
+}
+
+// AreaCoveredBy returns the number of square inches claimed by at least k
+// cuts. Each cut enters the coverTree at its left edge and leaves it at its
+// right edge, and the covered length is measured between edges.
+func (f *Fabric) AreaCoveredBy(k int) int {
+	if len(f.ys) < 2 {
+		return 0
+	}
+	yIndex := make(map[int]int, len(f.ys))
+	for i, y := range f.ys {
+		yIndex[y] = i
+	}
+
+	type edge struct {
+		x     int
+		cut   *Cut
+		delta int
+	}
+	edges := make([]edge, 0, 2*len(f.cuts))
+	for _, c := range f.byLeftEdge() {
+		edges = append(edges, edge{c.x, c, 1}, edge{c.x + c.w, c, -1})
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].x < edges[j].x })
+
+	tree := newCoverTree(f.ys, max(k, 0))
+	area := 0
+	for i := 0; i+1 < len(f.xs); i++ {
+		x0, x1 := f.xs[i], f.xs[i+1]
+		for len(edges) > 0 && edges[0].x == x0 {
+			c := edges[0].cut
+			tree.Add(yIndex[c.y], yIndex[c.y+c.h], edges[0].delta)
+			edges = edges[1:]
+		}
+		area += tree.Covered() * (x1 - x0)
+	}
+	return area
+}
+
+// OverlappedArea returns the number of square inches claimed by two or more
+// cuts.
+func (f *Fabric) OverlappedArea() int {
+	return f.AreaCoveredBy(2)
+}
+
+// SharedArea returns the number of square inches claimed by both cuts.
+func (c1 *Cut) SharedArea(c2 *Cut) int {
+	w := min(c1.x+c1.w, c2.x+c2.w) - max(c1.x, c2.x)
+	h := min(c1.y+c1.h, c2.y+c2.h) - max(c1.y, c2.y)
+	if w <= 0 || h <= 0 {
+		return 0
+	}
+	return w * h
+}
+
+// Conflict is a pair of overlapping cuts and the area they share.
+type Conflict struct {
+	A    *Cut
+	B    *Cut
+	Area int
+}
+
+// Conflicts returns every pair of overlapping cuts, ordered by the ids of the
+// cuts.
+func (f *Fabric) Conflicts() []Conflict {
+	conflicts := make([]Conflict, 0)
+	active := make([]*Cut, 0)
+	for _, c := range f.byLeftEdge() {
+		active = stillOpen(active, c.x)
+		for _, a := range active {
+			if c.Overlaps(a) {
+				if a.id < c.id {
+					conflicts = append(conflicts, Conflict{a, c, a.SharedArea(c)})
+				} else {
+					conflicts = append(conflicts, Conflict{c, a, c.SharedArea(a)})
+				}
+			}
+		}
+		active = append(active, c)
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].A.id != conflicts[j].A.id {
+			return conflicts[i].A.id < conflicts[j].A.id
+		}
+		return conflicts[i].B.id < conflicts[j].B.id
+	})
+	return conflicts
+}
+
+// NonOverlapping returns the cuts that share no square inch with any other
+// cut, in their original order.
+func (f *Fabric) NonOverlapping() []*Cut {
+	overlapping := map[*Cut]bool{}
+	for _, conflict := range f.Conflicts() {
+		overlapping[conflict.A] = true
+		overlapping[conflict.B] = true
+	}
+
+	result := make([]*Cut, 0)
+	for _, c := range f.cuts {
+		if !overlapping[c] {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// ConflictReport describes the conflict graph of a fabric: which cuts overlap
+// which, and the groups of cuts connected through overlaps.
+type ConflictReport struct {
+	Cuts       []*Cut
+	Overlaps   map[*Cut][]Conflict
+	Components [][]*Cut
+	Component  map[*Cut]int
+}
+
+func NewConflictReport(f *Fabric) *ConflictReport {
+	conflicts := f.Conflicts()
+
+	parent := map[*Cut]*Cut{}
+	var find func(c *Cut) *Cut
+	find = func(c *Cut) *Cut {
+		if p, ok := parent[c]; ok && p != c {
+			parent[c] = find(p)
+			return parent[c]
+		}
+		return c
+	}
+
+	overlaps := map[*Cut][]Conflict{}
+	for _, conflict := range conflicts {
+		overlaps[conflict.A] = append(overlaps[conflict.A], conflict)
+		overlaps[conflict.B] = append(overlaps[conflict.B], Conflict{conflict.B, conflict.A, conflict.Area})
+		parent[find(conflict.A)] = find(conflict.B)
+	}
+	for _, list := range overlaps {
+		sort.Slice(list, func(i, j int) bool { return list[i].B.id < list[j].B.id })
+	}
+
+	components := make([][]*Cut, 0)
+	component := map[*Cut]int{}
+	index := map[*Cut]int{}
+	for _, c := range f.cuts {
+		if len(overlaps[c]) == 0 {
+			continue
+		}
+		root := find(c)
+		if _, ok := index[root]; !ok {
+			index[root] = len(components)
+			components = append(components, make([]*Cut, 0))
+		}
+		component[c] = index[root]
+		components[index[root]] = append(components[index[root]], c)
+	}
+	return &ConflictReport{f.cuts, overlaps, components, component}
+}
+
+// SharedArea returns the total area a cut shares with other cuts, counting
+// area shared with several cuts once per cut.
+func (r *ConflictReport) SharedArea(c *Cut) int {
+	area := 0
+	for _, conflict := range r.Overlaps[c] {
+		area += conflict.Area
+	}
+	return area
+}
+
+func (r *ConflictReport) Print(w io.Writer) {
+	for _, c := range r.Cuts {
+		if len(r.Overlaps[c]) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "#%v overlaps %v cuts, sharing %v square inches:", c.id, len(r.Overlaps[c]), r.SharedArea(c))
+		for _, conflict := range r.Overlaps[c] {
+			fmt.Fprintf(w, " #%v (%v)", conflict.B.id, conflict.Area)
+		}
+		fmt.Fprintln(w)
+	}
+	for i, component := range r.Components {
+		fmt.Fprintf(w, "Component %v:", i)
+		for _, c := range component {
+			fmt.Fprintf(w, " #%v", c.id)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// WriteCSV writes one row per overlapping pair and direction, so the rows of
+// a single cut list everything it conflicts with.
+func (r *ConflictReport) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"claim", "other", "shared_area", "component"}); err != nil {
+		return err
+	}
+	for _, c := range r.Cuts {
+		for _, conflict := range r.Overlaps[c] {
+			row := []string{
+				strconv.Itoa(c.id),
+				strconv.Itoa(conflict.B.id),
+				strconv.Itoa(conflict.Area),
+				strconv.Itoa(r.Component[c]),
+			}
+			if err := out.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteDOT writes the conflict graph for Graphviz, with one cluster per
+// component and edges labelled by the shared area.
+func (r *ConflictReport) WriteDOT(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "graph conflicts {")
+	for i, component := range r.Components {
+		fmt.Fprintf(b, "\tsubgraph cluster_%v {\n", i)
+		for _, c := range component {
+			fmt.Fprintf(b, "\t\t%v [label=\"#%v\\n%v\"];\n", c.id, c.id, r.SharedArea(c))
+		}
+		fmt.Fprintln(b, "\t}")
+	}
+	for _, c := range r.Cuts {
+		for _, conflict := range r.Overlaps[c] {
+			if conflict.A.id < conflict.B.id {
+				fmt.Fprintf(b, "\t%v -- %v [label=\"%v\"];\n", conflict.A.id, conflict.B.id, conflict.Area)
+			}
+		}
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
+
 var cutPattern, _ = regexp.Compile(`#(\d+)\s@\s(\d+),(\d+):\s(\d+)x(\d+)`)
 
 func loadFile(filename string) []*Cut {
@@ -66,47 +414,47 @@ func parseLine(line string) *Cut {
 }
 
 func part1(cuts []*Cut) {
-	const size = 1000
-	fabric := make([]int, size*size)
-
-	for i := 0; i < len(cuts); i++ {
-		for x := cuts[i].x; x < (cuts[i].x + cuts[i].w); x++ {
-			for y := cuts[i].y; y < (cuts[i].y + cuts[i].h); y++ {
-				fabric[x+y*size]++
-			}
-		}
-	}
-
-	sum := 0
-	for i := 0; i < len(fabric); i++ {
-		if fabric[i] >= 2 {
-			sum++
-		}
-	}
-
+	sum := NewFabric(cuts).OverlappedArea()
 	fmt.Printf("Part 1: Total overlapping fabric = %v\n", sum)
 }
 
 func part2(cuts []*Cut) {
-	for i := 0; i < len(cuts); i++ {
-		overlaps := false
-		for j := 0; j < len(cuts); j++ {
-			if i == j {
-				continue
-			}
-			if cuts[i].Overlaps(cuts[j]) {
-				overlaps = true
-				break
-			}
-		}
-		if !overlaps {
-			fmt.Printf("%v does not overlap with any other cut\n", cuts[i])
-		}
+	for _, c := range NewFabric(cuts).NonOverlapping() {
+		fmt.Printf("%v does not overlap with any other cut\n", c)
+	}
+}
+
+func writeFile(filename string, write func(w io.Writer) error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	if err := write(file); err != nil {
+		log.Fatal(err)
 	}
 }
 
 func main() {
+	report := flag.Bool("conflicts", false, "print the conflicts of every cut")
+	csvFile := flag.String("csv", "", "write the conflict report as CSV to this file")
+	dotFile := flag.String("dot", "", "write the conflict graph as DOT to this file")
+	flag.Parse()
+
 	cuts := loadFile("input.txt")
 	part1(cuts)
 	part2(cuts)
+
+	if *report || *csvFile != "" || *dotFile != "" {
+		r := NewConflictReport(NewFabric(cuts))
+		if *report {
+			r.Print(os.Stdout)
+		}
+		if *csvFile != "" {
+			writeFile(*csvFile, r.WriteCSV)
+		}
+		if *dotFile != "" {
+			writeFile(*dotFile, r.WriteDOT)
+		}
+	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -20,3 +21,123 @@ func TestParseLine(t *testing.T) {
 		t.Fatalf("Failed to parse: %v", c)
 	}
 }
+
+func TestOverlaps(t *testing.T) {
+	c1 := parseLine("#1 @ 1,3: 4x4")
+	c2 := parseLine("#2 @ 3,1: 4x4")
+	c3 := parseLine("#3 @ 5,5: 2x2")
+	if !c1.Overlaps(c2) || !c2.Overlaps(c1) {
+		t.Errorf("Expected %v and %v to overlap.", c1, c2)
+	}
+	if c1.Overlaps(c3) || c3.Overlaps(c1) {
+		t.Errorf("Expected %v and %v only to touch.", c1, c3)
+	}
+}
+
+func TestFabric(t *testing.T) {
+	cuts := []*Cut{
+		parseLine("#1 @ 1,3: 4x4"),
+		parseLine("#2 @ 3,1: 4x4"),
+		parseLine("#3 @ 5,5: 2x2"),
+	}
+	f := NewFabric(cuts)
+	if area := f.OverlappedArea(); area != 4 {
+		t.Errorf("Expected overlapped area 4, got %v.", area)
+	}
+	if area := f.AreaCoveredBy(1); area != 32 {
+		t.Errorf("Expected covered area 32, got %v.", area)
+	}
+	if free := f.NonOverlapping(); len(free) != 1 || free[0].id != 3 {
+		t.Errorf("Expected only #3 to be free, got %v.", free)
+	}
+}
+
+func TestFabricBeyondThousand(t *testing.T) {
+	cuts := []*Cut{
+		parseLine("#1 @ 999,5: 10x10"),
+		parseLine("#2 @ 1005,10: 10x10"),
+		parseLine("#3 @ 1000,7: 2x2"),
+		parseLine("#4 @ 100000,100000: 3x3"),
+	}
+	f := NewFabric(cuts)
+	if area := f.OverlappedArea(); area != 4*5+4 {
+		t.Errorf("Expected overlapped area 24, got %v.", area)
+	}
+	if area := f.AreaCoveredBy(3); area != 0 {
+		t.Errorf("Expected no area covered three times, got %v.", area)
+	}
+	if free := f.NonOverlapping(); len(free) != 1 || free[0].id != 4 {
+		t.Errorf("Expected only #4 to be free, got %v.", free)
+	}
+}
+
+func TestAreaCoveredByGrid(t *testing.T) {
+	cuts := make([]*Cut, 0)
+	for i := 0; i < 40; i++ {
+		cuts = append(cuts, &Cut{i + 1, i * 7 % 23, i * 11 % 19, 1 + i*5%9, 1 + i*3%8})
+	}
+	grid := [32][32]int{}
+	for _, c := range cuts {
+		for x := c.x; x < c.x+c.w; x++ {
+			for y := c.y; y < c.y+c.h; y++ {
+				grid[x][y]++
+			}
+		}
+	}
+	f := NewFabric(cuts)
+	for k := 1; k <= 6; k++ {
+		expected := 0
+		for x := range grid {
+			for y := range grid[x] {
+				if grid[x][y] >= k {
+					expected++
+				}
+			}
+		}
+		if area := f.AreaCoveredBy(k); area != expected {
+			t.Errorf("Expected area %v covered by %v cuts, got %v.", expected, k, area)
+		}
+	}
+}
+
+func TestConflictReport(t *testing.T) {
+	cuts := []*Cut{
+		parseLine("#1 @ 1,3: 4x4"),
+		parseLine("#2 @ 3,1: 4x4"),
+		parseLine("#3 @ 5,5: 2x2"),
+		parseLine("#4 @ 6,2: 3x1"),
+		parseLine("#5 @ 20,20: 5x5"),
+		parseLine("#6 @ 22,22: 5x5"),
+	}
+	r := NewConflictReport(NewFabric(cuts))
+	if n := len(r.Overlaps[cuts[1]]); n != 2 {
+		t.Errorf("Expected #2 to overlap 2 cuts, got %v.", n)
+	}
+	if area := r.SharedArea(cuts[1]); area != 5 {
+		t.Errorf("Expected #2 to share 5 square inches, got %v.", area)
+	}
+	if len(r.Components) != 2 ||
+		len(r.Components[0]) != 3 ||
+		len(r.Components[1]) != 2 ||
+		r.Component[cuts[5]] != 1 {
+		t.Errorf("Expected components {1, 2, 4} and {5, 6}, got %v.", r.Components)
+	}
+	if _, ok := r.Component[cuts[2]]; ok {
+		t.Errorf("Expected #3 not to be part of any component.")
+	}
+
+	var b strings.Builder
+	if err := r.WriteCSV(&b); err != nil {
+		t.Fatal(err)
+	}
+	expected := "claim,other,shared_area,component\n" +
+		"1,2,4,0\n" +
+		"2,1,4,0\n" +
+		"2,4,1,0\n" +
+		"4,2,1,0\n" +
+		"5,6,9,1\n" +
+		"6,5,9,1\n"
+	if b.String() != expected {
+		t.Errorf("Expected CSV\n%v\ngot\n%v", expected, b.String())
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -21,12 +23,15 @@ const (
 	WakeUp
 )
 
+// minutes is the length of the midnight hour, which the puzzle answers are
+// based on.
 const minutes = 60
 
 type Event struct {
 	EventType EventType
 	TimeStamp time.Time
 	GuardId   int
+	Line      int
 }
 
 type EventList []*Event
@@ -44,21 +49,24 @@ func (e EventList) Swap(i, j int) {
 }
 
 func loadFile(filename string) EventList {
-	var eventPattern, err = regexp.Compile(`\[(\d{4})-(\d{2})-(\d{2})\s(\d{2}):(\d{2})\]\s(Guard #(\d+) begins shift|wakes up|falls asleep)`)
+	file, err := os.Open(filename)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer file.Close()
+	return readEvents(file)
+}
 
-	file, err := os.Open(filename)
+func readEvents(r io.Reader) EventList {
+	var eventPattern, err = regexp.Compile(`\[(\d{4})-(\d{2})-(\d{2})\s(\d{2}):(\d{2})\]\s(Guard #(\d+) begins shift|wakes up|falls asleep)`)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer file.Close()
 
 	events := make(EventList, 0)
 
-	reader := bufio.NewReader(file)
-	for {
+	reader := bufio.NewReader(r)
+	for lineNumber := 1; ; lineNumber++ {
 		line, _, err := reader.ReadLine()
 		if err != nil {
 			if err == io.EOF {
@@ -67,9 +75,17 @@ func loadFile(filename string) EventList {
 			log.Fatal(err)
 		}
 		trimmedLine := strings.TrimSpace(string(line))
-		events = append(events, parseLine(eventPattern, trimmedLine))
+		if trimmedLine == "" {
+			continue
+		}
+		event := parseLine(eventPattern, trimmedLine)
+		if event == nil {
+			log.Fatalf("line %v: cannot parse %q", lineNumber, trimmedLine)
+		}
+		event.Line = lineNumber
+		events = append(events, event)
 	}
-	sort.Sort(events)
+	sort.Stable(events)
 
 	var guardId int
 	for _, event := range events {
@@ -85,6 +101,9 @@ func loadFile(filename string) EventList {
 
 func parseLine(p *regexp.Regexp, s string) *Event {
 	matches := p.FindStringSubmatch(s)
+	if matches == nil {
+		return nil
+	}
 	year, _ := strconv.Atoi(matches[1])
 	month, _ := strconv.Atoi(matches[2])
 	day, _ := strconv.Atoi(matches[3])
@@ -101,101 +120,304 @@ func parseLine(p *regexp.Regexp, s string) *Event {
 	}
 	return &Event{event,
 		time.Date(year, time.Month(month), day, hour, minute, 0, 0, time.UTC),
-		guardId}
+		guardId, 0}
+}
+
+// Problem is an inconsistency in the guard log, reported against the line of
+// the input file it was found on. A warning is something the schedules
+// handle but the answers, which only look at the midnight hour, do not.
+type Problem struct {
+	Line    int
+	Message string
+	Warning bool
+}
+
+func (p Problem) String() string {
+	if p.Warning {
+		return fmt.Sprintf("line %v: warning: %v", p.Line, p.Message)
+	}
+	return fmt.Sprintf("line %v: %v", p.Line, p.Message)
 }
 
+// fatal returns the problems that are not warnings.
+func fatal(problems []Problem) []Problem {
+	result := make([]Problem, 0)
+	for _, problem := range problems {
+		if !problem.Warning {
+			result = append(result, problem)
+		}
+	}
+	return result
+}
+
+// validate checks the sorted events for everything initializeGrid takes for
+// granted. Any problem found that is not a warning means the schedules built
+// from the events would be wrong.
+func validate(events EventList) []Problem {
+	problems := make([]Problem, 0)
+	report := func(line int, format string, a ...interface{}) {
+		problems = append(problems, Problem{line, fmt.Sprintf(format, a...), false})
+	}
+	warn := func(line int, format string, a ...interface{}) {
+		problems = append(problems, Problem{line, fmt.Sprintf(format, a...), true})
+	}
+
+	var asleep *Event
+	onShift := false
+	for i, event := range events {
+		if i > 0 && event.TimeStamp.Equal(events[i-1].TimeStamp) {
+			report(event.Line, "duplicate timestamp %v, also on line %v",
+				event.TimeStamp.Format("2006-01-02 15:04"), events[i-1].Line)
+		}
+
+		switch event.EventType {
+		case BeginShift:
+			if asleep != nil {
+				report(asleep.Line, "guard #%v falls asleep but never wakes up", asleep.GuardId)
+				asleep = nil
+			}
+			onShift = true
+		case FallAsleep:
+			if !onShift {
+				report(event.Line, "falls asleep before any guard begins shift")
+				continue
+			}
+			if asleep != nil {
+				report(asleep.Line, "guard #%v falls asleep but never wakes up", asleep.GuardId)
+			}
+			asleep = event
+		case WakeUp:
+			if !onShift {
+				report(event.Line, "wakes up before any guard begins shift")
+				continue
+			}
+			if asleep == nil {
+				report(event.Line, "guard #%v wakes up without falling asleep", event.GuardId)
+				continue
+			}
+			hour := asleep.TimeStamp.Truncate(time.Hour)
+			if event.TimeStamp.After(hour.Add(time.Hour)) {
+				warn(event.Line, "guard #%v sleeps across the hour, from %v (line %v) to %v",
+					event.GuardId, asleep.TimeStamp.Format("15:04"), asleep.Line,
+					event.TimeStamp.Format("15:04"))
+			}
+			asleep = nil
+		}
+	}
+	if asleep != nil {
+		report(asleep.Line, "guard #%v falls asleep but never wakes up", asleep.GuardId)
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+	return problems
+}
+
+// Nap is a period asleep, from the minute a guard falls asleep up to but not
+// including the minute the guard wakes up.
+type Nap struct {
+	From time.Time
+	To   time.Time
+}
+
+func (n Nap) Minutes() int {
+	return int(n.To.Sub(n.From) / time.Minute)
+}
+
+// Schedule is one shift of a guard. Naps keep the real time ranges slept,
+// so a shift may span midnight and any number of hours. Minutes is the
+// midnight hour of Day, the calendar day the shift is guarding.
 type Schedule struct {
 	GuardId int
+	Begin   time.Time
+	Day     time.Time
+	Naps    []Nap
 	Minutes []int
+	asleep  *time.Time
 }
 
-func (s *Schedule) wakeUp(minute int) {
-	s.Minutes[minute] = -1
+func (s *Schedule) wakeUp(t time.Time) {
+	if s.asleep != nil {
+		s.Naps = append(s.Naps, Nap{*s.asleep, t})
+		s.asleep = nil
+	}
 }
 
-func (s *Schedule) fallAsleep(minute int) {
-	s.Minutes[minute] = 1
+func (s *Schedule) fallAsleep(t time.Time) {
+	s.asleep = &t
 }
 
 func (s *Schedule) finalize() {
-	for i := 1; i < minutes; i++ {
-		s.Minutes[i] = s.Minutes[i-1] + s.Minutes[i]
+	midnight := s.Day
+	for i := 0; i < minutes; i++ {
+		m := midnight.Add(time.Duration(i) * time.Minute)
+		s.Minutes[i] = s.MinutesAsleep(m, m.Add(time.Minute))
 	}
 }
 
-func MakeSchedule(guardId int) *Schedule {
-	return &Schedule{guardId, make([]int, minutes)}
+// MinutesAsleep returns the number of minutes slept within [from, to).
+func (s *Schedule) MinutesAsleep(from, to time.Time) int {
+	total := 0
+	for _, nap := range s.Naps {
+		start, end := nap.From, nap.To
+		if from.After(start) {
+			start = from
+		}
+		if to.Before(end) {
+			end = to
+		}
+		if end.After(start) {
+			total += Nap{start, end}.Minutes()
+		}
+	}
+	return total
 }
 
-func part1(events EventList) {
-	grid := initializeGrid(events)
-	guardIds := uniqueGuardIds(events)
-	findGuardMostAsleep(guardIds, grid)
-	//for _, id := range guardIds {
-	//	sum := sumGuard(id, grid)
-	//	fmt.Printf("%v : %v\n", id, sum)
-	//}
+// shiftDay returns the calendar day guarded by a shift beginning at t. Shifts
+// beginning in the evening guard the following night.
+func shiftDay(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if t.Hour() >= 12 {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
 }
 
-func part2(events EventList) {
-	grid := initializeGrid(events)
-	guardIds := uniqueGuardIds(events)
-	guardMostAsleep := 0
-	maxSleep := 0
-	maxMinute := 0
-	for _, id := range guardIds {
-		sum := sumGuard(id, grid)
-		for m, v := range sum {
-			if v > maxSleep {
-				guardMostAsleep = id
-				maxSleep = v
-				maxMinute = m
+func MakeSchedule(guardId int, begin time.Time) *Schedule {
+	return &Schedule{guardId, begin, shiftDay(begin), nil, make([]int, minutes), nil}
+}
+
+// GuardStats summarises every shift of one guard. Histogram is the sum of
+// the guard's schedules as returned by sumGuard.
+type GuardStats struct {
+	GuardId    int   `json:"guard"`
+	Histogram  []int `json:"histogram"`
+	Total      int   `json:"total"`
+	PeakMinute int   `json:"peak_minute"`
+	PeakCount  int   `json:"peak_count"`
+	Nights     int   `json:"nights_asleep"`
+	LongestNap int   `json:"longest_nap"`
+}
+
+func NewGuardStats(guardId int, grid []*Schedule) *GuardStats {
+	stats := &GuardStats{GuardId: guardId, Histogram: sumGuard(guardId, grid)}
+	for m, v := range stats.Histogram {
+		stats.Total += v
+		if v > stats.PeakCount {
+			stats.PeakMinute = m
+			stats.PeakCount = v
+		}
+	}
+	for _, s := range grid {
+		if s.GuardId != guardId || len(s.Naps) == 0 {
+			continue
+		}
+		stats.Nights++
+		for _, nap := range s.Naps {
+			if nap.Minutes() > stats.LongestNap {
+				stats.LongestNap = nap.Minutes()
 			}
 		}
 	}
-	fmt.Printf("GuardId = %[1]v, Minute = %[2]v (%[4]v), %[1]v * %[2]v = %[3]v\n",
-		guardMostAsleep, maxMinute, guardMostAsleep*maxMinute, maxSleep)
+	return stats
 }
 
-func findGuardMostAsleep(guardIds []int, grid []*Schedule) (guardId, frequency, minute, total int) {
-	type Result struct {
-		index     int
-		frequency int
-		total     int
+func guardStats(guardIds []int, grid []*Schedule) []*GuardStats {
+	stats := make([]*GuardStats, 0, len(guardIds))
+	for _, id := range guardIds {
+		stats = append(stats, NewGuardStats(id, grid))
 	}
-	result := map[int]Result{}
+	return stats
+}
 
-	for _, id := range guardIds {
-		sum := sumGuard(id, grid)
-		maxIdx := 0
-		maxFreq := 0
-		total := 0
-		for i := 0; i < minutes; i++ {
-			total += sum[i]
-			if sum[i] > maxFreq {
-				maxIdx = i
-				maxFreq = sum[i]
-			}
+// Strategy picks the guard to sneak past. The guard with the highest score
+// is chosen, and the answer is its id times its peak minute.
+type Strategy interface {
+	Name() string
+	Score(g *GuardStats) int
+}
+
+type MostMinutesAsleep struct{}
+
+func (MostMinutesAsleep) Name() string            { return "most minutes asleep" }
+func (MostMinutesAsleep) Score(g *GuardStats) int { return g.Total }
+
+type MostFrequentMinute struct{}
+
+func (MostFrequentMinute) Name() string            { return "most frequently asleep on one minute" }
+func (MostFrequentMinute) Score(g *GuardStats) int { return g.PeakCount }
+
+type LongestNap struct{}
+
+func (LongestNap) Name() string            { return "longest continuous nap" }
+func (LongestNap) Score(g *GuardStats) int { return g.LongestNap }
+
+type MostSleepyNights struct{}
+
+func (MostSleepyNights) Name() string            { return "most nights asleep" }
+func (MostSleepyNights) Score(g *GuardStats) int { return g.Nights }
+
+// choose returns the guard with the highest score, preferring the first one
+// on ties.
+func choose(strategy Strategy, stats []*GuardStats) *GuardStats {
+	var best *GuardStats
+	for _, g := range stats {
+		if best == nil || strategy.Score(g) > strategy.Score(best) {
+			best = g
 		}
-		result[id] = Result{maxIdx, maxFreq, total}
 	}
+	return best
+}
+
+func printChoice(strategy Strategy, stats []*GuardStats) {
+	g := choose(strategy, stats)
+	if g == nil {
+		fmt.Printf("%v: no guards\n", strategy.Name())
+		return
+	}
+	fmt.Printf("%[1]v: id = %[2]v, minute = %[3]v (%[4]v), %[2]v * %[3]v = %[5]v\n",
+		strategy.Name(), g.GuardId, g.PeakMinute, strategy.Score(g), g.GuardId*g.PeakMinute)
+}
+
+func part1(events EventList) {
+	grid := initializeGrid(events)
+	printChoice(MostMinutesAsleep{}, guardStats(uniqueGuardIds(events), grid))
+}
+
+func part2(events EventList) {
+	grid := initializeGrid(events)
+	printChoice(MostFrequentMinute{}, guardStats(uniqueGuardIds(events), grid))
+}
 
-	max := 0
-	maxId := 0
-	var maxResult Result
-	for k, r := range result {
-		if r.total > max {
-			max = r.total
-			maxResult = r
-			maxId = k
+// histogramChars draws a histogram with one character per minute: '.' when
+// never asleep, then 1-9 and a-z, and '#' for anything larger.
+func histogramChars(histogram []int) string {
+	const digits = "123456789abcdefghijklmnopqrstuvwxyz"
+	var b strings.Builder
+	for _, v := range histogram {
+		switch {
+		case v == 0:
+			b.WriteByte('.')
+		case v <= len(digits):
+			b.WriteByte(digits[v-1])
+		default:
+			b.WriteByte('#')
 		}
 	}
+	return b.String()
+}
 
-	fmt.Println(maxResult)
-	fmt.Printf("id = %[1]v, minute = %[2]v, %[1]v * %[2]v = %[3]v\n", maxId, maxResult.index,
-		maxId*maxResult.index)
+func printReport(w io.Writer, stats []*GuardStats) {
+	fmt.Fprintf(w, "%6s %6s %5s %6s %4s  %v\n", "Guard", "Total", "Peak", "Nights", "Nap", "Minutes")
+	for _, g := range stats {
+		fmt.Fprintf(w, "%6d %6d %2d:%-2d %6d %4d  %v\n",
+			g.GuardId, g.Total, g.PeakMinute, g.PeakCount, g.Nights, g.LongestNap, histogramChars(g.Histogram))
+	}
+}
 
-	return 0, 0, 0, 0
+func writeReportJSON(w io.Writer, stats []*GuardStats) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(stats)
 }
 
 func sumGuard(guardId int, grid []*Schedule) []int {
@@ -243,11 +465,11 @@ func initializeGrid(events EventList) []*Schedule {
 				grid[row].finalize()
 			}
 			row++
-			grid[row] = MakeSchedule(event.GuardId)
+			grid[row] = MakeSchedule(event.GuardId, event.TimeStamp)
 		case WakeUp:
-			grid[row].wakeUp(event.TimeStamp.Minute())
+			grid[row].wakeUp(event.TimeStamp)
 		case FallAsleep:
-			grid[row].fallAsleep(event.TimeStamp.Minute())
+			grid[row].fallAsleep(event.TimeStamp)
 		}
 	}
 	grid[row].finalize()
@@ -255,7 +477,34 @@ func initializeGrid(events EventList) []*Schedule {
 }
 
 func main() {
+	report := flag.String("report", "", "print a sleep report per guard, as table or json")
+	flag.Parse()
+
 	events := loadFile("input.txt")
+	problems := validate(events)
+	for _, problem := range problems {
+		log.Println(problem)
+	}
+	if errs := fatal(problems); len(errs) > 0 {
+		log.Fatalf("%v problems found in the guard log", len(errs))
+	}
 	part1(events)
 	part2(events)
+
+	stats := guardStats(uniqueGuardIds(events), initializeGrid(events))
+	for _, strategy := range []Strategy{LongestNap{}, MostSleepyNights{}} {
+		printChoice(strategy, stats)
+	}
+
+	switch *report {
+	case "":
+	case "table":
+		printReport(os.Stdout, stats)
+	case "json":
+		if err := writeReportJSON(os.Stdout, stats); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unknown report format %q", *report)
+	}
 }
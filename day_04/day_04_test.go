@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const exampleLog = `[1518-11-01 00:00] Guard #10 begins shift
+[1518-11-01 00:05] falls asleep
+[1518-11-01 00:25] wakes up
+[1518-11-01 00:30] falls asleep
+[1518-11-01 00:55] wakes up
+[1518-11-01 23:58] Guard #99 begins shift
+[1518-11-02 00:40] falls asleep
+[1518-11-02 00:50] wakes up
+[1518-11-03 00:05] Guard #10 begins shift
+[1518-11-03 00:24] falls asleep
+[1518-11-03 00:29] wakes up
+[1518-11-04 00:02] Guard #99 begins shift
+[1518-11-04 00:36] falls asleep
+[1518-11-04 00:46] wakes up
+[1518-11-05 00:03] Guard #99 begins shift
+[1518-11-05 00:45] falls asleep
+[1518-11-05 00:55] wakes up
+`
+
+func TestValidateExample(t *testing.T) {
+	events := readEvents(strings.NewReader(exampleLog))
+	if problems := validate(events); len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v.", problems)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	log := `[1518-10-31 23:50] wakes up
+[1518-11-01 00:00] Guard #10 begins shift
+[1518-11-01 00:05] falls asleep
+[1518-11-01 00:10] falls asleep
+[1518-11-01 00:25] wakes up
+[1518-11-01 00:30] wakes up
+[1518-11-02 00:00] Guard #99 begins shift
+[1518-11-02 00:40] falls asleep
+[1518-11-02 01:10] wakes up
+[1518-11-02 01:10] falls asleep
+`
+	expected := []Problem{
+		{1, "wakes up before any guard begins shift", false},
+		{3, "guard #10 falls asleep but never wakes up", false},
+		{6, "guard #10 wakes up without falling asleep", false},
+		{9, "guard #99 sleeps across the hour, from 00:40 (line 8) to 01:10", true},
+		{10, "duplicate timestamp 1518-11-02 01:10, also on line 9", false},
+		{10, "guard #99 falls asleep but never wakes up", false},
+	}
+
+	problems := validate(readEvents(strings.NewReader(log)))
+	if errs := fatal(problems); len(errs) != len(expected)-1 {
+		t.Errorf("Expected every problem but the warning to be an error, got %v.", errs)
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("Expected %v, got %v.", expected, problems)
+	}
+	for i := range expected {
+		if problems[i] != expected[i] {
+			t.Errorf("Expected %v, got %v.", expected[i], problems[i])
+		}
+	}
+}
+
+func TestStrategies(t *testing.T) {
+	events := readEvents(strings.NewReader(exampleLog))
+	stats := guardStats(uniqueGuardIds(events), initializeGrid(events))
+
+	if g := choose(MostMinutesAsleep{}, stats); g.GuardId != 10 || g.Total != 50 || g.PeakMinute != 24 {
+		t.Errorf("Expected guard 10 with 50 minutes peaking at 24, got %+v.", g)
+	}
+	if g := choose(MostFrequentMinute{}, stats); g.GuardId != 99 || g.PeakMinute != 45 || g.PeakCount != 3 {
+		t.Errorf("Expected guard 99 asleep 3 times on minute 45, got %+v.", g)
+	}
+	if g := choose(LongestNap{}, stats); g.GuardId != 10 || g.LongestNap != 25 {
+		t.Errorf("Expected guard 10 with a 25 minute nap, got %+v.", g)
+	}
+	if g := choose(MostSleepyNights{}, stats); g.GuardId != 99 || g.Nights != 3 {
+		t.Errorf("Expected guard 99 asleep on 3 nights, got %+v.", g)
+	}
+}
+
+func TestScheduleAcrossMidnight(t *testing.T) {
+	log := `[1518-11-01 23:50] Guard #10 begins shift
+[1518-11-01 23:58] falls asleep
+[1518-11-02 00:03] wakes up
+[1518-11-02 00:50] falls asleep
+[1518-11-02 01:10] wakes up
+`
+	grid := initializeGrid(readEvents(strings.NewReader(log)))
+	s := grid[0]
+	day := time.Date(1518, 11, 2, 0, 0, 0, 0, time.UTC)
+	if !s.Day.Equal(day) {
+		t.Errorf("Expected the shift to guard %v, got %v.", day, s.Day)
+	}
+	total := 0
+	for _, v := range s.Minutes {
+		total += v
+	}
+	if total != 13 || s.Minutes[2] != 1 || s.Minutes[3] != 0 || s.Minutes[49] != 0 || s.Minutes[59] != 1 {
+		t.Errorf("Wrong midnight hour %v.", s.Minutes)
+	}
+	if m := s.MinutesAsleep(day.AddDate(0, 0, -1), day); m != 2 {
+		t.Errorf("Expected 2 minutes asleep on the day before, got %v.", m)
+	}
+	if m := s.MinutesAsleep(day, day.AddDate(0, 0, 1)); m != 23 {
+		t.Errorf("Expected 23 minutes asleep on the shift day, got %v.", m)
+	}
+}
@@ -1,103 +1,368 @@
 package main
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
 	"golang.org/x/tools/container/intsets"
-	"io/ioutil"
+	"io"
 	"log"
+	"os"
+	"sort"
 	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Units []rune
 
-func loadFile(filename string) Units {
-	bytes, err := ioutil.ReadFile(filename)
+// Rules describes which adjacent units react. Two units either annihilate,
+// leaving nothing behind, or transform into a single product unit which may
+// react again with its new neighbour. Reactions apply in either order.
+type Rules struct {
+	opposites  bool
+	annihilate map[[2]rune]bool
+	transform  map[[2]rune]rune
+}
+
+func NewRules() *Rules {
+	return &Rules{false, map[[2]rune]bool{}, map[[2]rune]rune{}}
+}
+
+// CaseRules are the rules of the puzzle: a unit annihilates with the same
+// letter in the opposite case. Any Unicode letter with a case counts.
+func CaseRules() *Rules {
+	r := NewRules()
+	r.opposites = true
+	return r
+}
+
+func (r *Rules) Annihilate(a, b rune) *Rules {
+	r.annihilate[[2]rune{a, b}] = true
+	r.annihilate[[2]rune{b, a}] = true
+	return r
+}
+
+func (r *Rules) Transform(a, b, product rune) *Rules {
+	r.transform[[2]rune{a, b}] = product
+	r.transform[[2]rune{b, a}] = product
+	return r
+}
+
+// casePair tells whether a and b are the same letter in opposite cases.
+// Characters like the Kelvin sign lower to a letter that does not upper back
+// to them, so both directions are checked.
+func casePair(a, b rune) bool {
+	return a == unicode.ToUpper(b) && b == unicode.ToLower(a) ||
+		b == unicode.ToUpper(a) && a == unicode.ToLower(b)
+}
+
+// UnitType returns the type a unit belongs to when removing units. With the
+// case rule both polarities of a letter are one type.
+func (r *Rules) UnitType(u rune) rune {
+	if lower := unicode.ToLower(u); r.opposites && casePair(u, lower) {
+		return lower
+	}
+	return u
+}
+
+// onlyCase tells whether the rules are just the case rule of the puzzle.
+func (r *Rules) onlyCase() bool {
+	return r.opposites && len(r.annihilate) == 0 && len(r.transform) == 0
+}
+
+// React returns what two adjacent units turn into. A product of 0 means
+// that both units are gone.
+func (r *Rules) React(a, b rune) (product rune, ok bool) {
+	if r.annihilate[[2]rune{a, b}] {
+		return 0, true
+	}
+	if p, ok := r.transform[[2]rune{a, b}]; ok {
+		return p, true
+	}
+	if r.opposites && a != b && casePair(a, b) {
+		return 0, true
+	}
+	return 0, false
+}
+
+// ParseRules reads a rule description with one rule per line:
+//
+//	case       units annihilate with the same letter in the opposite case
+//	a b ->     a next to b annihilates
+//	a b -> c   a next to b turns into c
+//
+// Units are single runes, so any Unicode character can be used. Everything
+// after a # is a comment.
+func ParseRules(in io.Reader) (*Rules, error) {
+	rules := NewRules()
+	scanner := bufio.NewScanner(in)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case len(fields) == 1 && fields[0] == "case":
+			rules.opposites = true
+		case (len(fields) == 3 || len(fields) == 4) && fields[2] == "->":
+			units := make([]rune, 0, 3)
+			for i, f := range fields {
+				if i == 2 {
+					continue
+				}
+				u, err := parseUnit(f)
+				if err != nil {
+					return nil, fmt.Errorf("line %v: %v", lineNumber, err)
+				}
+				units = append(units, u)
+			}
+			if len(units) == 2 {
+				rules.Annihilate(units[0], units[1])
+			} else {
+				rules.Transform(units[0], units[1], units[2])
+			}
+		default:
+			return nil, fmt.Errorf("line %v: cannot parse rule %q", lineNumber, scanner.Text())
+		}
+	}
+	return rules, scanner.Err()
+}
+
+func parseUnit(field string) (rune, error) {
+	if field == "->" || field == "case" {
+		return 0, fmt.Errorf("expected a unit, got %q", field)
+	}
+	if utf8.RuneCountInString(field) != 1 {
+		return 0, fmt.Errorf("%q is not a single unit", field)
+	}
+	u, _ := utf8.DecodeRuneInString(field)
+	return u, nil
+}
+
+func loadRules(filename string) *Rules {
+	file, err := os.Open(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	rules, err := ParseRules(file)
 	if err != nil {
 		log.Fatal(err)
 	}
-	trimmedLine := strings.TrimSpace(string(bytes))
-	return []rune(trimmedLine)
+	return rules
 }
 
-func singleReduce(units Units) Units {
-	// Rune difference between reactive units.
-	const diff = 32
-	if len(units) < 2 {
-		return units
+var caseRules = CaseRules()
+
+// Reducer reduces a polymer one unit at a time. The units reduced so far are
+// kept as a stack: a new unit either reacts with the top of the stack or is
+// pushed onto it, so a full reduction is linear in the polymer length.
+type Reducer struct {
+	rules *Rules
+	stack Units
+	skip  rune
+}
+
+// NewReducer returns a reducer that drops every unit of the given type, as
+// given by Rules.UnitType, before it can react. Products of that type are
+// dropped as well. A skip of 0 keeps every unit.
+func NewReducer(rules *Rules, skip rune) *Reducer {
+	if skip != 0 {
+		skip = rules.UnitType(skip)
 	}
-	for i := 1; i < len(units); i++ {
-		d := units[i] - units[i-1]
-		if d == diff || d == -diff {
-			var first, second Units
-			if i > 1 {
-				first = units[0 : i-1]
-			} else {
-				first = Units{}
+	return &Reducer{rules, make(Units, 0), skip}
+}
+
+func (r *Reducer) skipped(u rune) bool {
+	return r.skip != 0 && r.rules.UnitType(u) == r.skip
+}
+
+func (r *Reducer) Push(u rune) {
+	if r.skipped(u) {
+		return
+	}
+	for {
+		n := len(r.stack)
+		if n == 0 {
+			break
+		}
+		product, ok := r.rules.React(r.stack[n-1], u)
+		if !ok {
+			break
+		}
+		r.stack = r.stack[:n-1]
+		if product == 0 || r.skipped(product) {
+			return
+		}
+		u = product
+	}
+	r.stack = append(r.stack, u)
+}
+
+func (r *Reducer) Units() Units {
+	return r.stack
+}
+
+func fullReduce(units Units) Units {
+	return reduceWithout(caseRules, units, 0)
+}
+
+func reduceWithout(rules *Rules, units Units, skip rune) Units {
+	r := NewReducer(rules, skip)
+	for _, u := range units {
+		r.Push(u)
+	}
+	return r.Units()
+}
+
+// reduceReader reduces a polymer read from r without holding more than the
+// reduced polymer in memory. Whitespace is ignored.
+func reduceReader(rules *Rules, in io.Reader) (Units, error) {
+	r := NewReducer(rules, 0)
+	reader := bufio.NewReader(in)
+	for {
+		u, _, err := reader.ReadRune()
+		if err != nil {
+			if err == io.EOF {
+				return r.Units(), nil
 			}
-			if i < len(units)-1 {
-				second = units[i+1:]
-			} else {
-				second = Units{}
+			return nil, err
+		}
+		if !unicode.IsSpace(u) {
+			r.Push(u)
+		}
+	}
+}
+
+// readUnits reads a polymer without reducing it. Whitespace is ignored.
+func readUnits(in io.Reader) (Units, error) {
+	units := make(Units, 0)
+	reader := bufio.NewReader(in)
+	for {
+		u, _, err := reader.ReadRune()
+		if err != nil {
+			if err == io.EOF {
+				return units, nil
 			}
-			return append(first, second...)
+			return nil, err
 		}
+		if !unicode.IsSpace(u) {
+			units = append(units, u)
+		}
+	}
+}
+
+func loadUnits(filename string) Units {
+	file, err := os.Open(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	units, err := readUnits(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return units
+}
+
+func reduceFile(rules *Rules, filename string) Units {
+	file, err := os.Open(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	units, err := reduceReader(rules, file)
+	if err != nil {
+		log.Fatal(err)
 	}
 	return units
 }
 
-func part1(units Units) {
-	units = fullReduce(units)
+func part1(rules *Rules, units Units) {
 	fmt.Printf("%v : %v\n", len(units), string(units))
 
 	unitCount := map[rune]bool{}
 	for _, u := range units {
-		var c rune
-		if u >= 'a' {
-			c = u - 32
-		} else {
-			c = u
-		}
-		unitCount[c] = true
+		unitCount[rules.UnitType(u)] = true
 	}
 	fmt.Printf("We got %v units left.\n", len(unitCount))
 }
 
-func fullReduce(units Units) Units {
-	previousLength := len(units)
-	for {
-		units = singleReduce(units)
-		if len(units) == previousLength {
-			break
-		}
-		previousLength = len(units)
+// unitTypes returns the types of the units in the polymer and of the
+// products of the rules, in order.
+func unitTypes(rules *Rules, units Units) []rune {
+	seen := map[rune]bool{}
+	for _, u := range units {
+		seen[rules.UnitType(u)] = true
 	}
-	return units
+	for _, product := range rules.transform {
+		seen[rules.UnitType(product)] = true
+	}
+	types := make([]rune, 0, len(seen))
+	for t := range seen {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
 }
 
-func part2(units Units) {
-	s := string(units)
-	stats := make([]int, 0)
-	for c := 'A'; c <= 'Z'; c++ {
-		s1 := strings.Replace(s, string(c), "", -1)
-		s2 := strings.Replace(s1, string(c+32), "", -1)
-		reduced := fullReduce(Units(s2))
-		stats = append(stats, len(reduced))
+// bestRemoval returns the unit type whose removal leaves the shortest
+// polymer, and that length. The polymer has to be the original one, except
+// with only the case rule: removing a unit type then never stops two other
+// units from reacting, so the polymer reduced in part 1 gives the same
+// lengths.
+func bestRemoval(rules *Rules, units Units) (rune, int) {
+	types := unitTypes(rules, units)
+	stats := make([]int, len(types))
+	var wg sync.WaitGroup
+	for i, t := range types {
+		wg.Add(1)
+		go func(i int, t rune) {
+			defer wg.Done()
+			stats[i] = len(reduceWithout(rules, units, t))
+		}(i, t)
 	}
-	minIndex := 0
+	wg.Wait()
+
+	minType := rune(0)
 	minValue := intsets.MaxInt
 	for i, v := range stats {
 		if v < minValue {
-			minIndex = i
+			minType = types[i]
 			minValue = v
 		}
 	}
+	return minType, minValue
+}
+
+func part2(rules *Rules, units Units) {
+	unitType, length := bestRemoval(rules, units)
+	if rules.opposites {
+		unitType = unicode.ToUpper(unitType)
+	}
 	fmt.Println("Part2:")
-	fmt.Printf("Min value = %v for %v\n", minValue, string(minIndex+'A'))
+	fmt.Printf("Min value = %v for %c\n", length, unitType)
 }
 
 func main() {
-	units := loadFile("input.txt")
-	fmt.Println(len(units))
-	part1(units)
-	part2(units)
+	rulesFile := flag.String("rules", "", "read the reaction rules from this file instead")
+	flag.Parse()
+
+	rules := caseRules
+	if *rulesFile != "" {
+		rules = loadRules(*rulesFile)
+	}
+	units := reduceFile(rules, "input.txt")
+	part1(rules, units)
+	if !rules.onlyCase() {
+		units = loadUnits("input.txt")
+	}
+	part2(rules, units)
 }
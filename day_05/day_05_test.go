@@ -1,20 +1,135 @@
 package main
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
-func testUnits(t *testing.T, s string, e string) {
-	if r := string(singleReduce(Units(s))); r != e {
-		t.Errorf("Expected %v, got %v", e, r)
+func TestReducer(t *testing.T) {
+	cases := map[string]string{
+		"aA":               "",
+		"abBA":             "",
+		"abAB":             "abAB",
+		"aabAAB":           "aabAAB",
+		"dabAcCaCBAcCcaDA": "dabCBAcaDA",
+		"dabCBAcaDA":       "dabCBAcaDA",
+	}
+	for polymer, e := range cases {
+		r := NewReducer(caseRules, 0)
+		for _, u := range polymer {
+			r.Push(u)
+		}
+		if string(r.Units()) != e {
+			t.Errorf("%v: expected %v, got %v", polymer, e, string(r.Units()))
+		}
+	}
+}
+
+func TestFullReduce(t *testing.T) {
+	if r := string(fullReduce(Units("dabAcCaCBAcCcaDA"))); r != "dabCBAcaDA" {
+		t.Errorf("Expected dabCBAcaDA, got %v", r)
+	}
+	if r := string(fullReduce(Units("aAbBcCdD"))); r != "" {
+		t.Errorf("Expected an empty polymer, got %v", r)
+	}
+}
+
+func TestReduceReader(t *testing.T) {
+	r, err := reduceReader(caseRules, strings.NewReader("dabAcCaCBAcCcaDA\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(r) != "dabCBAcaDA" {
+		t.Errorf("Expected dabCBAcaDA, got %v", string(r))
 	}
 }
 
-func TestSingleReduce(t *testing.T) {
-	testUnits(t, "aA", "")
-	testUnits(t, "abBA", "aA")
-	testUnits(t, "abAB", "abAB")
-	testUnits(t, "aabAAB", "aabAAB")
-	testUnits(t, "dabAcCaCBAcCcaDA", "dabAaCBAcCcaDA")
-	testUnits(t, "dabAaCBAcCcaDA", "dabCBAcCcaDA")
-	testUnits(t, "dabCBAcCcaDA", "dabCBAcaDA")
-	testUnits(t, "dabCBAcaDA", "dabCBAcaDA")
+func TestReduceWithout(t *testing.T) {
+	expected := map[rune]string{'a': "dbCBcD", 'B': "daCAcaDA", 'c': "daDA", 'D': "abCBAc"}
+	units := fullReduce(Units("dabAcCaCBAcCcaDA"))
+	for skip, e := range expected {
+		if r := string(reduceWithout(caseRules, units, skip)); r != e {
+			t.Errorf("Without %c expected %v, got %v", skip, e, r)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader(`# Greek polarity and a catalyst.
+case
+x y ->
+a b -> c
+c c -> ✓
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"αΑβ":   "β",
+		"zxyz":  "zz",
+		"yx":    "",
+		"ab":    "c",
+		"abc":   "✓",
+		"abba":  "✓",
+		"dabAD": "dcAD",
+	}
+	for polymer, e := range cases {
+		if r := string(reduceWithout(rules, Units(polymer), 0)); r != e {
+			t.Errorf("%v: expected %v, got %v", polymer, e, r)
+		}
+	}
+
+	for _, line := range []string{"ab c ->", "a case ->", "a b -> ->", "-> -> ->", "case a", "a b c"} {
+		if _, err := ParseRules(strings.NewReader(line)); err == nil {
+			t.Errorf("Expected an error for %q.", line)
+		}
+	}
+}
+
+func TestCasePairs(t *testing.T) {
+	// The Kelvin sign lowers to k but is not the upper case of k.
+	cases := map[string]string{"Kk": "", "kK": "", "\u212Ak": "\u212Ak", "K\u212A": "K\u212A", "ǅǆ": "ǅǆ", "ǄǆDž": "Dž"}
+	for polymer, e := range cases {
+		if r := string(reduceWithout(caseRules, Units(polymer), 0)); r != e {
+			t.Errorf("%q: expected %q, got %q", polymer, e, r)
+		}
+	}
+	if caseRules.UnitType('\u212A') == caseRules.UnitType('K') {
+		t.Error("Expected the Kelvin sign to be a unit type of its own.")
+	}
+	if u := NewRules().UnitType('K'); u != 'K' {
+		t.Errorf("Expected K to be its own type without the case rule, got %c.", u)
+	}
+}
+
+func TestBestRemoval(t *testing.T) {
+	unitType, length := bestRemoval(caseRules, fullReduce(Units("dabAcCaCBAcCcaDA")))
+	if unitType != 'c' || length != 4 {
+		t.Errorf("Expected to remove c leaving 4 units, got %c leaving %v.", unitType, length)
+	}
+
+	rules := CaseRules().Transform('a', 'b', 'c')
+	polymer := Units("abCd")
+	if r := string(reduceWithout(rules, polymer, 'b')); r != "aCd" {
+		t.Errorf("Without b expected aCd, got %v", r)
+	}
+	// The product of a and b is dropped too when c is removed.
+	if r := string(reduceWithout(rules, polymer, 'c')); r != "d" {
+		t.Errorf("Without c expected d, got %v", r)
+	}
+	if types := string(unitTypes(rules, polymer)); types != "abcd" {
+		t.Errorf("Expected unit types abcd, got %v", types)
+	}
+	// a and b turn into c, which annihilates with C.
+	unitType, length = bestRemoval(rules, polymer)
+	if unitType != 'd' || length != 0 {
+		t.Errorf("Expected to remove d leaving nothing, got %c leaving %v.", unitType, length)
+	}
+
+	unicodeRules := NewRules().Annihilate('α', 'ω')
+	unitType, length = bestRemoval(unicodeRules, Units("αβωβ"))
+	if unitType != 'β' || length != 0 {
+		t.Errorf("Expected to remove β leaving nothing, got %c leaving %v.", unitType, length)
+	}
 }
@@ -2,9 +2,11 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"golang.org/x/tools/container/intsets"
 	"log"
+	"math"
 	"os"
 )
 
@@ -19,34 +21,47 @@ type PointList []*Point
 const occupied = -1
 
 type Location struct {
-	closestPointId int
-	totalDistance  int
+	closestPointId  int
+	closestDistance int
+	totalDistance   int
 }
 
+// Area is the grid of locations over the bounding box of the points and of
+// every finite region under the metric, grown by a margin on every side. Locations are indexed relative to minX and minY,
+// so points may have negative coordinates.
 type Area struct {
+	minX     int
+	minY     int
 	maxX     int
 	maxY     int
+	metric   Metric
+	points   PointList
 	location [][]Location
 }
 
-func NewArea(points PointList) *Area {
-	maxX := intsets.MinInt
-	maxY := intsets.MinInt
+func NewArea(points PointList, metric Metric, margin int) *Area {
+	minX, minY, maxX, maxY := metric.Bounds(points)
 	for _, p := range points {
-		if p.x > maxX {
-			maxX = p.x
-		}
-		if p.y > maxY {
-			maxY = p.y
-		}
+		minX, maxX = min(minX, p.x), max(maxX, p.x)
+		minY, maxY = min(minY, p.y), max(maxY, p.y)
 	}
-	maxX += 5
-	maxY += 5
-	locations := make([][]Location, maxX)
-	for i := 0; i < maxX; i++ {
-		locations[i] = make([]Location, maxY)
+	minX -= margin
+	minY -= margin
+	maxX += margin
+	maxY += margin
+	locations := make([][]Location, maxX-minX+1)
+	for i := range locations {
+		locations[i] = make([]Location, maxY-minY+1)
 	}
-	return &Area{maxX, maxY, locations}
+	return &Area{minX, minY, maxX, maxY, metric, points, locations}
+}
+
+func (a *Area) contains(x, y int) bool {
+	return x >= a.minX && x <= a.maxX && y >= a.minY && y <= a.maxY
+}
+
+func (a *Area) at(x, y int) *Location {
+	return &a.location[x-a.minX][y-a.minY]
 }
 
 type Distances []int
@@ -67,48 +82,102 @@ func (v Distances) Min() (min, count, id int) {
 	return min, count, id + 1
 }
 
+// Fill finds the closest point of every location. Metrics that are the
+// shortest path length over some set of grid steps are filled by a breadth
+// first search from all points at once, others by comparing every location
+// with every point.
 func (a *Area) Fill(points PointList) {
+	if steps := a.metric.Steps(); steps != nil {
+		a.fillSearch(points, steps)
+	} else {
+		a.fillBrute(points)
+	}
+}
+
+func (a *Area) fillBrute(points PointList) {
 	distances := make(Distances, len(points))
-	for x := 0; x < a.maxX; x++ {
-		for y := 0; y < a.maxY; y++ {
+	for x := a.minX; x <= a.maxX; x++ {
+		for y := a.minY; y <= a.maxY; y++ {
 			testPoint := Point{0, x, y}
 			for i, p := range points {
-				d := testPoint.ManhattanDistance(p)
-				distances[i] = d
+				distances[i] = a.metric.Distance(&testPoint, p)
 			}
-			_, count, id := distances.Min()
+			d, count, id := distances.Min()
+			l := a.at(x, y)
+			l.closestDistance = d
 			if count > 1 {
-				a.location[x][y].closestPointId = occupied
+				l.closestPointId = occupied
 			} else {
-				a.location[x][y].closestPointId = id
+				l.closestPointId = points[id-1].id
 			}
 		}
 	}
 }
 
-func (a *Area) LargestBoundedArea(points PointList) (int, int) {
-	const unbounded = -1
-	areas := make([]int, len(points))
-	var id int
-	for x := 0; x < a.maxX; x++ {
-		for y := 0; y < a.maxY; y++ {
-			id = a.location[x][y].closestPointId
-			if id == -1 {
+// fillSearch grows all regions one step at a time. A location is tied when
+// the locations it is reached from are tied or belong to different points.
+func (a *Area) fillSearch(points PointList, steps [][2]int) {
+	const unvisited = 0
+	for x := range a.location {
+		for y := range a.location[x] {
+			a.location[x][y] = Location{unvisited, -1, 0}
+		}
+	}
+
+	queue := make([][2]int, 0, len(points))
+	for _, p := range points {
+		l := a.at(p.x, p.y)
+		if l.closestDistance == 0 {
+			l.closestPointId = occupied
+			continue
+		}
+		*l = Location{p.id, 0, 0}
+		queue = append(queue, [2]int{p.x, p.y})
+	}
+
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		from := a.at(c[0], c[1])
+		for _, step := range steps {
+			x, y := c[0]+step[0], c[1]+step[1]
+			if !a.contains(x, y) {
 				continue
 			}
-			if x == 0 || y == 0 || x == a.maxX-1 || y == a.maxY-1 {
-				areas[id-1] = unbounded
-				continue
+			l := a.at(x, y)
+			switch {
+			case l.closestDistance < 0:
+				*l = Location{from.closestPointId, from.closestDistance + 1, 0}
+				queue = append(queue, [2]int{x, y})
+			case l.closestDistance == from.closestDistance+1 && l.closestPointId != from.closestPointId:
+				l.closestPointId = occupied
 			}
-			if areas[id-1] != unbounded {
-				areas[id-1]++
+		}
+	}
+}
+
+// LargestBoundedArea returns the point with the largest finite region. The
+// metric decides which regions are infinite, and NewArea makes room for the
+// finite ones using the metric's Bounds.
+func (a *Area) LargestBoundedArea(points PointList) (int, int) {
+	infinite := a.metric.Infinite(points)
+	index := map[int]int{}
+	for i, p := range points {
+		index[p.id] = i
+	}
+
+	areas := make([]int, len(points))
+	for x := range a.location {
+		for y := range a.location[x] {
+			if id := a.location[x][y].closestPointId; id != occupied {
+				areas[index[id]]++
 			}
 		}
 	}
 	maxArea := 0
 	maxId := 0
 	for i, p := range points {
-		if areas[i] > maxArea {
+		if !infinite[i] && areas[i] > maxArea {
 			maxArea = areas[i]
 			maxId = p.id
 		}
@@ -117,9 +186,9 @@ func (a *Area) LargestBoundedArea(points PointList) (int, int) {
 }
 
 func (a *Area) Print() {
-	for y := 0; y < a.maxY; y++ {
-		for x := 0; x < a.maxX; x++ {
-			fmt.Printf("%4d", a.location[x][y].closestPointId)
+	for y := a.minY; y <= a.maxY; y++ {
+		for x := a.minX; x <= a.maxX; x++ {
+			fmt.Printf("%4d", a.at(x, y).closestPointId)
 		}
 		fmt.Println()
 	}
@@ -137,6 +206,227 @@ func (p1 *Point) ManhattanDistance(p2 *Point) int {
 	return dx + dy
 }
 
+// Metric is a distance between grid locations.
+type Metric interface {
+	Distance(p1, p2 *Point) int
+	// Steps returns the moves of a grid walk whose shortest path length is
+	// the distance, or nil if there is no such walk.
+	Steps() [][2]int
+	// Infinite reports, for every point, whether infinitely many locations
+	// are closer to it than to any other point.
+	Infinite(points PointList) []bool
+	// Bounds returns a box holding every location of every finite region.
+	Bounds(points PointList) (minX, minY, maxX, maxY int)
+}
+
+type Manhattan struct{}
+
+func (Manhattan) Distance(p1, p2 *Point) int {
+	return p1.ManhattanDistance(p2)
+}
+
+func (Manhattan) Steps() [][2]int {
+	return [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+}
+
+func (Manhattan) Infinite(points PointList) []bool {
+	us := make([]int, len(points))
+	vs := make([]int, len(points))
+	for i, p := range points {
+		us[i], vs[i] = p.x, p.y
+	}
+	return unboundedManhattan(us, vs)
+}
+
+// Bounds is the bounding box of the points. Beyond it in any direction the
+// distances to all points grow alike, so a location there has the same
+// closest point as the nearest location on the box, and that region is
+// infinite.
+func (Manhattan) Bounds(points PointList) (minX, minY, maxX, maxY int) {
+	minX, minY = intsets.MaxInt, intsets.MaxInt
+	maxX, maxY = intsets.MinInt, intsets.MinInt
+	for _, p := range points {
+		minX, maxX = min(minX, p.x), max(maxX, p.x)
+		minY, maxY = min(minY, p.y), max(maxY, p.y)
+	}
+	return minX, minY, maxX, maxY
+}
+
+type Chebyshev struct{}
+
+func (Chebyshev) Distance(p1, p2 *Point) int {
+	return max(abs(p1.x-p2.x), abs(p1.y-p2.y))
+}
+
+func (Chebyshev) Steps() [][2]int {
+	return [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+}
+
+// Infinite uses that the Chebyshev distance is half the Manhattan distance
+// in coordinates rotated by 45 degrees.
+func (Chebyshev) Infinite(points PointList) []bool {
+	us := make([]int, len(points))
+	vs := make([]int, len(points))
+	for i, p := range points {
+		us[i], vs[i] = p.x+p.y, p.x-p.y
+	}
+	return unboundedManhattan(us, vs)
+}
+
+// Bounds is the bounding box of the points in the rotated coordinates, as
+// for Manhattan, turned back into a box in x and y. Dividing by two
+// truncates towards zero, which never cuts off a location.
+func (Chebyshev) Bounds(points PointList) (minX, minY, maxX, maxY int) {
+	minU, minV := intsets.MaxInt, intsets.MaxInt
+	maxU, maxV := intsets.MinInt, intsets.MinInt
+	for _, p := range points {
+		minU, maxU = min(minU, p.x+p.y), max(maxU, p.x+p.y)
+		minV, maxV = min(minV, p.x-p.y), max(maxV, p.x-p.y)
+	}
+	return (minU + minV) / 2, (minU - maxV) / 2, (maxU + maxV) / 2, (maxU - minV) / 2
+}
+
+type EuclideanSquared struct{}
+
+func (EuclideanSquared) Distance(p1, p2 *Point) int {
+	dx := p1.x - p2.x
+	dy := p1.y - p2.y
+	return dx*dx + dy*dy
+}
+
+func (EuclideanSquared) Steps() [][2]int {
+	return nil
+}
+
+// Infinite marks the points on the boundary of the convex hull. Points in
+// the middle of a hull edge own an infinite strip perpendicular to it.
+func (EuclideanSquared) Infinite(points PointList) []bool {
+	infinite := make([]bool, len(points))
+	for i, p := range points {
+		infinite[i] = onHull(p, points)
+	}
+	return infinite
+}
+
+// Bounds is the bounding box of the corners of the finite regions. Each
+// corner is a Voronoi vertex: the centre of a circle through three points
+// with no point inside it.
+func (m EuclideanSquared) Bounds(points PointList) (minX, minY, maxX, maxY int) {
+	minX, minY, maxX, maxY = Manhattan{}.Bounds(points)
+	infinite := m.Infinite(points)
+	for i, a := range points {
+		for j := i + 1; j < len(points); j++ {
+			for k := j + 1; k < len(points); k++ {
+				if infinite[i] && infinite[j] && infinite[k] {
+					continue
+				}
+				x, y, ok := voronoiVertex(a, points[j], points[k], points)
+				if ok {
+					minX, maxX = min(minX, int(math.Floor(x))), max(maxX, int(math.Ceil(x)))
+					minY, maxY = min(minY, int(math.Floor(y))), max(maxY, int(math.Ceil(y)))
+				}
+			}
+		}
+	}
+	return minX, minY, maxX, maxY
+}
+
+// voronoiVertex returns the centre of the circle through a, b and c if no
+// point lies inside it. Rounding errors err on the side of accepting it.
+func voronoiVertex(a, b, c *Point, points PointList) (float64, float64, bool) {
+	d := 2 * (a.x*(b.y-c.y) + b.x*(c.y-a.y) + c.x*(a.y-b.y))
+	if d == 0 {
+		return 0, 0, false
+	}
+	a2, b2, c2 := a.x*a.x+a.y*a.y, b.x*b.x+b.y*b.y, c.x*c.x+c.y*c.y
+	x := float64(a2*(b.y-c.y)+b2*(c.y-a.y)+c2*(a.y-b.y)) / float64(d)
+	y := float64(a2*(c.x-b.x)+b2*(a.x-c.x)+c2*(b.x-a.x)) / float64(d)
+	r := (x-float64(a.x))*(x-float64(a.x)) + (y-float64(a.y))*(y-float64(a.y))
+	for _, p := range points {
+		dx, dy := x-float64(p.x), y-float64(p.y)
+		if dx*dx+dy*dy < r-1e-9*(1+r) {
+			return 0, 0, false
+		}
+	}
+	return x, y, true
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// unboundedManhattan decides which regions are infinite under the Manhattan
+// distance in (u, v) coordinates. Far enough along +u at a fixed v = b, the
+// distance to q is a constant plus |b - v_q| - u_q, so the point minimising
+// that alone owns the whole ray. Outside the range of v the order no longer
+// changes, so checking b one past either end covers the diagonals as well.
+// The other three directions follow by symmetry.
+func unboundedManhattan(us, vs []int) []bool {
+	infinite := make([]bool, len(us))
+	scan := func(along, across []int, sign int) {
+		lo, hi := intsets.MaxInt, intsets.MinInt
+		for _, c := range across {
+			lo, hi = min(lo, c), max(hi, c)
+		}
+		for b := lo - 1; b <= hi+1; b++ {
+			best, count, id := intsets.MaxInt, 0, -1
+			for i := range along {
+				f := abs(b-across[i]) - sign*along[i]
+				if f < best {
+					best, count, id = f, 1, i
+				} else if f == best {
+					count++
+				}
+			}
+			if count == 1 {
+				infinite[id] = true
+			}
+		}
+	}
+	scan(us, vs, 1)
+	scan(us, vs, -1)
+	scan(vs, us, 1)
+	scan(vs, us, -1)
+	return infinite
+}
+
+// onHull reports whether p lies on the boundary of the convex hull of the
+// points. That is the case when some line through p and another point has
+// all points on one side of it. A point with a duplicate owns no location.
+func onHull(p *Point, points PointList) bool {
+	others := 0
+	for _, q := range points {
+		if q != p && q.x == p.x && q.y == p.y {
+			return false
+		}
+		if q != p {
+			others++
+		}
+	}
+	if others < 2 {
+		return true
+	}
+
+	for _, q := range points {
+		if q == p {
+			continue
+		}
+		left, right := false, false
+		for _, r := range points {
+			cross := (q.x-p.x)*(r.y-p.y) - (q.y-p.y)*(r.x-p.x)
+			left = left || cross > 0
+			right = right || cross < 0
+		}
+		if !left || !right {
+			return true
+		}
+	}
+	return false
+}
+
 func loadData(filename string) PointList {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -159,54 +449,177 @@ func loadData(filename string) PointList {
 }
 
 func part1(points PointList) {
-	area := NewArea(points)
+	area := NewArea(points, Manhattan{}, 1)
 	area.Fill(points)
-	area.Print()
 	id, a := area.LargestBoundedArea(points)
 	fmt.Println(id, a)
 }
 
-func (a *Area) Fill2(points PointList) {
-	for x := 0; x < a.maxX; x++ {
-		for y := 0; y < a.maxY; y++ {
-			d := 0
-			for _, p := range points {
-				d += p.ManhattanDistance(&Point{0, x, y})
-			}
-			a.location[x][y].totalDistance = d
+func (a *Area) Fill2() {
+	for x := a.minX; x <= a.maxX; x++ {
+		for y := a.minY; y <= a.maxY; y++ {
+			a.at(x, y).totalDistance = a.totalDistance(x, y)
 		}
 	}
 }
 
 func (a *Area) Print2() {
-	for y := 0; y < a.maxY; y++ {
-		for x := 0; x < a.maxX; x++ {
-			fmt.Printf("%6d", a.location[x][y].totalDistance)
+	for y := a.minY; y <= a.maxY; y++ {
+		for x := a.minX; x <= a.maxX; x++ {
+			fmt.Printf("%6d", a.at(x, y).totalDistance)
 		}
 		fmt.Println()
 	}
 }
 
+func (a *Area) totalDistance(x, y int) int {
+	d := 0
+	for _, p := range a.points {
+		d += a.metric.Distance(p, &Point{0, x, y})
+	}
+	return d
+}
+
+// below returns the range of integers in [lo, hi] where the convex function
+// f is less than the threshold. Being convex, f is first non-increasing and
+// then non-decreasing, so every boundary can be found by bisection.
+func below(f func(int) int, lo, hi, threshold int) (int, int, bool) {
+	l, h := lo, hi
+	for l < h {
+		m := l + (h-l)/2
+		if f(m+1) < f(m) {
+			l = m + 1
+		} else {
+			h = m
+		}
+	}
+	bottom := l
+	if f(bottom) >= threshold {
+		return 0, 0, false
+	}
+
+	l, h = lo, bottom
+	for l < h {
+		m := l + (h-l)/2
+		if f(m) < threshold {
+			h = m
+		} else {
+			l = m + 1
+		}
+	}
+	first := l
+
+	l, h = bottom, hi
+	for l < h {
+		m := l + (h-l+1)/2
+		if f(m) < threshold {
+			l = m
+		} else {
+			h = m - 1
+		}
+	}
+	return first, l, true
+}
+
+// safeRange returns the rows and columns that can hold a location whose
+// total distance is less than the threshold. Every metric is at least the
+// distance along either axis, so the sums of axis distances bound the range.
+func (a *Area) safeRange(threshold int) (x0, x1, y0, y1 int, ok bool) {
+	if len(a.points) == 0 {
+		return 0, 0, 0, 0, false
+	}
+	minX, minY := intsets.MaxInt, intsets.MaxInt
+	maxX, maxY := intsets.MinInt, intsets.MinInt
+	for _, p := range a.points {
+		minX, maxX = min(minX, p.x), max(maxX, p.x)
+		minY, maxY = min(minY, p.y), max(maxY, p.y)
+	}
+	axisSum := func(coordinate func(p *Point) int) func(int) int {
+		return func(v int) int {
+			d := 0
+			for _, p := range a.points {
+				d += abs(v - coordinate(p))
+			}
+			return d
+		}
+	}
+	x0, x1, ok = below(axisSum(func(p *Point) int { return p.x }), minX-threshold, maxX+threshold, threshold)
+	if !ok {
+		return 0, 0, 0, 0, false
+	}
+	y0, y1, ok = below(axisSum(func(p *Point) int { return p.y }), minY-threshold, maxY+threshold, threshold)
+	return x0, x1, y0, y1, ok
+}
+
+// LocationsWithTotalDistanceLessThan counts every location, inside the area
+// or not, whose total distance to all points is less than the threshold.
+// The total distance is convex, so within a row the locations that count
+// form a single run.
 func (a *Area) LocationsWithTotalDistanceLessThan(threshold int) int {
+	x0, x1, y0, y1, ok := a.safeRange(threshold)
+	if !ok {
+		return 0
+	}
+
 	count := 0
-	for x := 0; x < a.maxX; x++ {
-		for y := 0; y < a.maxY; y++ {
-			if a.location[x][y].totalDistance < threshold {
-				count++
-			}
+	for y := y0; y <= y1; y++ {
+		row := func(x int) int { return a.totalDistance(x, y) }
+		if first, last, ok := below(row, x0, x1, threshold); ok {
+			count += last - first + 1
 		}
 	}
 	return count
 }
 
-func part2(points PointList) {
-	area := NewArea(points)
-	area.Fill2(points)
-	fmt.Println("Locations with total distance less than 10000:", area.LocationsWithTotalDistanceLessThan(10000))
+// SafeMargin returns the margin an area needs to hold the whole region with
+// a total distance less than the threshold.
+func (a *Area) SafeMargin(threshold int) int {
+	x0, x1, y0, y1, ok := a.safeRange(threshold)
+	if !ok {
+		return 0
+	}
+	minX, minY := intsets.MaxInt, intsets.MaxInt
+	maxX, maxY := intsets.MinInt, intsets.MinInt
+	for _, p := range a.points {
+		minX, maxX = min(minX, p.x), max(maxX, p.x)
+		minY, maxY = min(minY, p.y), max(maxY, p.y)
+	}
+	margin := 0
+	for y := y0; y <= y1; y++ {
+		row := func(x int) int { return a.totalDistance(x, y) }
+		if first, last, ok := below(row, x0, x1, threshold); ok {
+			margin = max(margin, minX-first, last-maxX, minY-y, y-maxY)
+		}
+	}
+	return margin
+}
+
+func part2(points PointList, threshold int) {
+	area := NewArea(points, Manhattan{}, 0)
+	fmt.Printf("Locations with total distance less than %v: %v\n",
+		threshold, area.LocationsWithTotalDistanceLessThan(threshold))
 }
 
 func main() {
+	threshold := flag.Int("threshold", 10000, "total distance limit of the safe region")
+	voronoiFile := flag.String("png", "", "write the closest point of every location as PNG to this file")
+	heatMapFile := flag.String("heatmap", "", "write the total distance of every location as PNG to this file")
+	scale := flag.Int("scale", 2, "pixels per location in images")
+	flag.Parse()
+
 	points := loadData("input.txt")
 	part1(points)
-	part2(points)
+	part2(points, *threshold)
+
+	if *voronoiFile != "" {
+		area := NewArea(points, Manhattan{}, 10)
+		area.Fill(points)
+		savePNG(*voronoiFile, area.VoronoiImage(*scale))
+	}
+	if *heatMapFile != "" {
+		margin := NewArea(points, Manhattan{}, 0).SafeMargin(*threshold) + 1
+		area := NewArea(points, Manhattan{}, margin)
+		area.Fill2()
+		savePNG(*heatMapFile, area.HeatMapImage(*threshold, *scale))
+	}
 }
@@ -0,0 +1,165 @@
+package main
+
+import "testing"
+
+func testPoints() PointList {
+	return PointList{{1, 1, 1}, {2, 1, 6}, {3, 8, 3}, {4, 3, 4}, {5, 5, 5}, {6, 8, 9}}
+}
+
+func TestLargestBoundedArea(t *testing.T) {
+	points := loadData("test_input.txt")
+	area := NewArea(points, Manhattan{}, 1)
+	area.Fill(points)
+	if id, a := area.LargestBoundedArea(points); id != 5 || a != 17 {
+		t.Errorf("Expected area 17 for point 5, got %v for point %v.", a, id)
+	}
+}
+
+func TestNegativeCoordinates(t *testing.T) {
+	points := testPoints()
+	for _, p := range points {
+		p.x -= 100
+		p.y -= 50
+	}
+	area := NewArea(points, Manhattan{}, 3)
+	area.Fill(points)
+	if id, a := area.LargestBoundedArea(points); id != 5 || a != 17 {
+		t.Errorf("Expected area 17 for point 5, got %v for point %v.", a, id)
+	}
+}
+
+func TestInfinite(t *testing.T) {
+	points := testPoints()
+	expected := []bool{true, true, true, false, false, true}
+	for _, metric := range []Metric{Manhattan{}, Chebyshev{}, EuclideanSquared{}} {
+		infinite := metric.Infinite(points)
+		for i := range expected {
+			if infinite[i] != expected[i] {
+				t.Errorf("%T: expected point %v infinite = %v.", metric, points[i].id, expected[i])
+			}
+		}
+	}
+
+	line := PointList{{1, 0, 0}, {2, 4, 0}, {3, 8, 0}, {4, 4, 5}}
+	if infinite := (EuclideanSquared{}).Infinite(line); !infinite[1] {
+		t.Errorf("Expected the middle of a hull edge to be infinite.")
+	}
+}
+
+// Finite regions must fit in the area whatever the margin.
+func TestLargestBoundedAreaMargin(t *testing.T) {
+	pointSets := []PointList{
+		testPoints(),
+		{{1, 1, 3}, {2, -5, -4}, {3, -1, 3}, {4, -6, -4}, {5, 5, 2}, {6, -6, 5}, {7, -4, -1}},
+	}
+	seed := 1
+	for n := 0; n < 20; n++ {
+		points := make(PointList, 0)
+		for i := 1; i <= 8; i++ {
+			seed = seed * 1103515245 % 2147483648
+			x := seed%21 - 10
+			seed = seed * 1103515245 % 2147483648
+			points = append(points, &Point{i, x, seed%21 - 10})
+		}
+		pointSets = append(pointSets, points)
+	}
+
+	for _, metric := range []Metric{Manhattan{}, Chebyshev{}, EuclideanSquared{}} {
+		for _, points := range pointSets {
+			small := NewArea(points, metric, 0)
+			small.Fill(points)
+			large := NewArea(points, metric, 200)
+			large.Fill(points)
+			id, a := small.LargestBoundedArea(points)
+			if expectedId, expected := large.LargestBoundedArea(points); id != expectedId || a != expected {
+				t.Errorf("%T %v: expected area %v for point %v, got %v for point %v.",
+					metric, points, expected, expectedId, a, id)
+			}
+		}
+	}
+
+	points := pointSets[1]
+	area := NewArea(points, EuclideanSquared{}, 0)
+	area.Fill(points)
+	if id, a := area.LargestBoundedArea(points); id != 1 || a != 481 {
+		t.Errorf("Expected area 481 for point 1, got %v for point %v.", a, id)
+	}
+}
+
+// The breadth first fill must agree with comparing every location with
+// every point.
+func TestFillSearch(t *testing.T) {
+	points := testPoints()
+	for _, metric := range []Metric{Manhattan{}, Chebyshev{}} {
+		search := NewArea(points, metric, 4)
+		search.Fill(points)
+		brute := NewArea(points, metric, 4)
+		brute.fillBrute(points)
+		for x := range search.location {
+			for y := range search.location[x] {
+				if search.location[x][y] != brute.location[x][y] {
+					t.Fatalf("%T: search %v differs from brute force %v at %v, %v.", metric,
+						search.location[x][y], brute.location[x][y], x+search.minX, y+search.minY)
+				}
+			}
+		}
+	}
+}
+
+func TestLocationsWithTotalDistanceLessThan(t *testing.T) {
+	points := testPoints()
+	area := NewArea(points, Manhattan{}, 0)
+	if n := area.LocationsWithTotalDistanceLessThan(32); n != 16 {
+		t.Errorf("Expected 16 locations, got %v.", n)
+	}
+
+	// Far beyond the bounding box, compare with counting a large enough grid.
+	for _, metric := range []Metric{Manhattan{}, Chebyshev{}, EuclideanSquared{}} {
+		for _, threshold := range []int{0, 20, 80, 300} {
+			area := NewArea(points, metric, 60)
+			area.Fill2()
+			expected := 0
+			for x := range area.location {
+				for y := range area.location[x] {
+					if area.location[x][y].totalDistance < threshold {
+						expected++
+					}
+				}
+			}
+			if n := area.LocationsWithTotalDistanceLessThan(threshold); n != expected {
+				t.Errorf("%T below %v: expected %v locations, got %v.", metric, threshold, expected, n)
+			}
+		}
+	}
+}
+
+func TestVoronoiImage(t *testing.T) {
+	points := testPoints()
+	area := NewArea(points, Manhattan{}, 1)
+	area.Fill(points)
+	img := area.VoronoiImage(3)
+	if b := img.Bounds(); b.Dx() != 10*3 || b.Dy() != 11*3 {
+		t.Fatalf("Expected a 30x33 image, got %v.", b)
+	}
+	// Location (5, 4) belongs to point 5, the largest finite region.
+	if c := img.RGBAAt(3*(5-area.minX), 3*(4-area.minY)); c != largestColor {
+		t.Errorf("Expected the largest region in %v, got %v.", largestColor, c)
+	}
+	if c := img.RGBAAt(3*(5-area.minX), 3*(1-area.minY)); c != tieColor {
+		t.Errorf("Expected a tie at 5, 1, got %v.", c)
+	}
+}
+
+func TestHeatMapImage(t *testing.T) {
+	points := testPoints()
+	area := NewArea(points, Manhattan{}, 1)
+	area.Fill2()
+	img := area.HeatMapImage(32, 1)
+	// Location (5, 3) is on the edge of the safe region next to point 5.
+	if c := img.RGBAAt(5-area.minX, 3-area.minY); c != contourColor {
+		t.Errorf("Expected the contour at 5, 3, got %v.", c)
+	}
+	if c := img.RGBAAt(5-area.minX, 5-area.minY); c != pointColor || c == contourColor {
+		t.Errorf("Expected point 5 to stand out from the contour, got %v.", c)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"math"
+	"os"
+)
+
+var (
+	tieColor     = color.RGBA{0, 0, 0, 255}
+	pointColor   = color.RGBA{255, 255, 255, 255}
+	largestColor = color.RGBA{230, 30, 40, 255}
+	contourColor = color.RGBA{255, 40, 220, 255}
+)
+
+// hsv converts a hue in degrees and saturation and value in [0, 1] to RGB.
+func hsv(h, s, v float64) color.RGBA {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return color.RGBA{uint8(255 * (r + m)), uint8(255 * (g + m)), uint8(255 * (b + m)), 255}
+}
+
+// regionColor spreads the hues of consecutive ids by the golden angle, so
+// neighbouring regions rarely look alike. Infinite regions are drawn in
+// washed out grey tones.
+func regionColor(id int, infinite bool) color.RGBA {
+	h := math.Mod(float64(id)*137.508, 360)
+	if infinite {
+		return hsv(h, 0.12, 0.55)
+	}
+	return hsv(h, 0.45, 0.9)
+}
+
+func fillCell(img *image.RGBA, x, y, scale int, c color.RGBA) {
+	for dx := 0; dx < scale; dx++ {
+		for dy := 0; dy < scale; dy++ {
+			img.SetRGBA(x*scale+dx, y*scale+dy, c)
+		}
+	}
+}
+
+// VoronoiImage draws every location in the colour of its closest point.
+// Ties are black, infinite regions grey, and the largest finite region red.
+// The area must have been filled.
+func (a *Area) VoronoiImage(scale int) *image.RGBA {
+	infinite := map[int]bool{}
+	for i, inf := range a.metric.Infinite(a.points) {
+		infinite[a.points[i].id] = inf
+	}
+	largest, _ := a.LargestBoundedArea(a.points)
+
+	img := image.NewRGBA(image.Rect(0, 0, len(a.location)*scale, len(a.location[0])*scale))
+	for x := range a.location {
+		for y := range a.location[x] {
+			id := a.location[x][y].closestPointId
+			var c color.RGBA
+			switch {
+			case id == occupied:
+				c = tieColor
+			case id == largest:
+				c = largestColor
+			default:
+				c = regionColor(id, infinite[id])
+			}
+			fillCell(img, x, y, scale, c)
+		}
+	}
+	for _, p := range a.points {
+		fillCell(img, p.x-a.minX, p.y-a.minY, scale, pointColor)
+	}
+	return img
+}
+
+// HeatMapImage draws the total distance of every location, from blue for
+// the smallest to yellow for the largest, with the edge of the region below
+// the threshold in magenta. The area must have been filled with Fill2.
+func (a *Area) HeatMapImage(threshold, scale int) *image.RGBA {
+	lo, hi := math.MaxInt, math.MinInt
+	for x := range a.location {
+		for y := range a.location[x] {
+			lo = min(lo, a.location[x][y].totalDistance)
+			hi = max(hi, a.location[x][y].totalDistance)
+		}
+	}
+	safe := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < len(a.location) && y < len(a.location[x]) &&
+			a.location[x][y].totalDistance < threshold
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, len(a.location)*scale, len(a.location[0])*scale))
+	for x := range a.location {
+		for y := range a.location[x] {
+			var c color.RGBA
+			if safe(x, y) && !(safe(x-1, y) && safe(x+1, y) && safe(x, y-1) && safe(x, y+1)) {
+				c = contourColor
+			} else {
+				t := 0.0
+				if hi > lo {
+					t = float64(a.location[x][y].totalDistance-lo) / float64(hi-lo)
+				}
+				c = hsv(240-180*t, 0.85, 0.35+0.6*t)
+			}
+			fillCell(img, x, y, scale, c)
+		}
+	}
+	for _, p := range a.points {
+		fillCell(img, p.x-a.minX, p.y-a.minY, scale, pointColor)
+	}
+	return img
+}
+
+func savePNG(filename string, img image.Image) {
+	file, err := os.Create(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// StepTiming is the window in which a step can run without delaying the end
+// of the graph. Slack is how far the step can slip, and is zero for steps on
+// a critical path.
+type StepTiming struct {
+	Duration       int
+	EarliestStart  int
+	EarliestFinish int
+	LatestStart    int
+	LatestFinish   int
+	Slack          int
+}
+
+// Analysis is the critical path analysis of a graph with unlimited workers.
+type Analysis struct {
+	Order         NodeList
+	Steps         map[Node]*StepTiming
+	Length        int
+	TotalWork     int
+	CriticalPaths []NodeList
+}
+
+func Analyze(graph *Graph, duration DurationFunc) (*Analysis, error) {
+	order, err := topologicalSort(graph)
+	if err != nil {
+		return nil, err
+	}
+	successors := graph.Successors()
+	predecessors := make(map[Node]NodeList, len(graph.nodes))
+	for _, e := range graph.edges {
+		predecessors[e.end] = append(predecessors[e.end], e.start)
+	}
+
+	a := &Analysis{Order: order, Steps: make(map[Node]*StepTiming, len(order))}
+	for _, n := range order {
+		step := &StepTiming{Duration: duration(n)}
+		for _, p := range predecessors[n] {
+			step.EarliestStart = max(step.EarliestStart, a.Steps[p].EarliestFinish)
+		}
+		step.EarliestFinish = step.EarliestStart + step.Duration
+		a.Steps[n] = step
+		a.Length = max(a.Length, step.EarliestFinish)
+		a.TotalWork += step.Duration
+	}
+	for i := len(order) - 1; i >= 0; i-- {
+		step := a.Steps[order[i]]
+		step.LatestFinish = a.Length
+		for _, s := range successors[order[i]] {
+			step.LatestFinish = min(step.LatestFinish, a.Steps[s].LatestStart)
+		}
+		step.LatestStart = step.LatestFinish - step.Duration
+		step.Slack = step.LatestStart - step.EarliestStart
+	}
+
+	// Follow the edges where one critical step hands over directly to the
+	// next, from the steps starting at zero to those ending the graph.
+	var follow func(path NodeList)
+	follow = func(path NodeList) {
+		last := a.Steps[path[len(path)-1]]
+		if last.EarliestFinish == a.Length {
+			a.CriticalPaths = append(a.CriticalPaths, append(NodeList{}, path...))
+			return
+		}
+		for _, s := range successors[path[len(path)-1]] {
+			if step := a.Steps[s]; step.Slack == 0 && step.EarliestStart == last.EarliestFinish {
+				follow(append(path, s))
+			}
+		}
+	}
+	for _, n := range order {
+		if step := a.Steps[n]; step.Slack == 0 && step.EarliestStart == 0 {
+			follow(NodeList{n})
+		}
+	}
+	return a, nil
+}
+
+// LowerBound is the shortest possible time for a number of workers: never
+// less than the critical path, nor less than the total work shared evenly.
+func (a *Analysis) LowerBound(workers int) int {
+	shared := (a.TotalWork + workers - 1) / workers
+	return max(a.Length, shared)
+}
+
+func (a *Analysis) Print(w io.Writer) {
+	fmt.Fprintf(w, "%6v %8v %6v %6v %6v %6v %6v\n", "Step", "Duration", "ES", "EF", "LS", "LF", "Slack")
+	for _, n := range a.Order {
+		s := a.Steps[n]
+		fmt.Fprintf(w, "%6v %8v %6v %6v %6v %6v %6v\n", n,
+			s.Duration, s.EarliestStart, s.EarliestFinish, s.LatestStart, s.LatestFinish, s.Slack)
+	}
+	for _, path := range a.CriticalPaths {
+		fmt.Fprint(w, "Critical path:")
+		for i, n := range path {
+			if i > 0 {
+				fmt.Fprint(w, " ->")
+			}
+			fmt.Fprintf(w, " %v", n)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "Lower bound with unlimited workers: %v seconds, total work %v seconds.\n",
+		a.Length, a.TotalWork)
+}
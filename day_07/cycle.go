@@ -0,0 +1,143 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// CycleError is returned when the steps cannot be ordered. Cycle is one
+// concrete cycle, with its first step repeated at the end, and Components
+// are all strongly connected components of more than one step.
+type CycleError struct {
+	Cycle      NodeList
+	Components []NodeList
+}
+
+func joinNodes(nodes NodeList, sep string) string {
+	names := make([]string, len(nodes))
+	for i, n := range nodes {
+		names[i] = string(n)
+	}
+	return strings.Join(names, sep)
+}
+
+func (e *CycleError) Error() string {
+	var b strings.Builder
+	b.WriteString("cycle detected: ")
+	b.WriteString(joinNodes(e.Cycle, " -> "))
+	for _, c := range e.Components {
+		b.WriteString("; strongly connected: ")
+		b.WriteString(joinNodes(c, ", "))
+	}
+	return b.String()
+}
+
+// stronglyConnected returns the strongly connected components of the graph
+// using Tarjan's algorithm, each sorted and in order of their first node.
+func stronglyConnected(graph *Graph) []NodeList {
+	successors := graph.Successors()
+	nodes := append(NodeList{}, graph.nodes...)
+	sort.Sort(nodes)
+
+	index := map[Node]int{}
+	low := map[Node]int{}
+	onStack := map[Node]bool{}
+	stack := make(NodeList, 0)
+	components := make([]NodeList, 0)
+
+	var visit func(n Node)
+	visit = func(n Node) {
+		index[n] = len(index)
+		low[n] = index[n]
+		stack = append(stack, n)
+		onStack[n] = true
+		for _, s := range successors[n] {
+			if _, ok := index[s]; !ok {
+				visit(s)
+				low[n] = min(low[n], low[s])
+			} else if onStack[s] {
+				low[n] = min(low[n], index[s])
+			}
+		}
+		if low[n] == index[n] {
+			component := make(NodeList, 0)
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == n {
+					break
+				}
+			}
+			sort.Sort(component)
+			components = append(components, component)
+		}
+	}
+	for _, n := range nodes {
+		if _, ok := index[n]; !ok {
+			visit(n)
+		}
+	}
+	sort.Slice(components, func(i, j int) bool { return components[i][0] < components[j][0] })
+	return components
+}
+
+// findCycle returns the shortest cycle through the first step of the
+// component, found by a breadth first search that stays inside it.
+func findCycle(graph *Graph, component NodeList) NodeList {
+	successors := graph.Successors()
+	inside := map[Node]bool{}
+	for _, n := range component {
+		inside[n] = true
+	}
+
+	start := component[0]
+	previous := map[Node]Node{}
+	queue := NodeList{start}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		next := append(NodeList{}, successors[n]...)
+		sort.Sort(next)
+		for _, s := range next {
+			if s == start {
+				cycle := NodeList{start}
+				for m := n; m != start; m = previous[m] {
+					cycle = append(cycle, m)
+				}
+				for i, j := 1, len(cycle)-1; i < j; i, j = i+1, j-1 {
+					cycle[i], cycle[j] = cycle[j], cycle[i]
+				}
+				return append(cycle, start)
+			}
+			if _, seen := previous[s]; !seen && inside[s] {
+				previous[s] = n
+				queue = append(queue, s)
+			}
+		}
+	}
+	return nil
+}
+
+// newCycleError describes the cycles of a graph that failed to sort.
+func newCycleError(graph *Graph) *CycleError {
+	err := &CycleError{}
+	for _, c := range stronglyConnected(graph) {
+		if len(c) > 1 {
+			err.Components = append(err.Components, c)
+		}
+	}
+	if len(err.Components) > 0 {
+		err.Cycle = findCycle(graph, err.Components[0])
+		return err
+	}
+	// Without a larger component the cycle is a step depending on itself.
+	for _, e := range graph.edges {
+		if e.start == e.end {
+			err.Cycle = NodeList{e.start, e.start}
+			break
+		}
+	}
+	return err
+}
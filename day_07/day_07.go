@@ -2,14 +2,20 @@ package main
 
 import (
 	"bufio"
-	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
-type Node rune
+// Node is a step, named by any identifier.
+type Node string
 
 type DirectedEdge struct {
 	start Node
@@ -30,12 +36,24 @@ func (n NodeList) Swap(i, j int) {
 	n[i], n[j] = n[j], n[i]
 }
 
+// String joins the step names, separated by commas unless every name is a
+// single rune as in the puzzle.
 func (n NodeList) String() string {
-	r := make([]rune, 0, len(n))
+	separator := ""
 	for _, node := range n {
-		r = append(r, rune(node))
+		if utf8.RuneCountInString(string(node)) > 1 {
+			separator = ","
+			break
+		}
+	}
+	var b strings.Builder
+	for i, node := range n {
+		if i > 0 {
+			b.WriteString(separator)
+		}
+		b.WriteString(string(node))
 	}
-	return string(r)
+	return b.String()
 }
 
 type EdgeList []DirectedEdge
@@ -52,9 +70,50 @@ func (e EdgeList) Swap(i, j int) {
 	e[i], e[j] = e[j], e[i]
 }
 
+// Attributes are optional settings of a step, such as its duration or the
+// skill a worker needs to perform it.
+type Attributes map[string]string
+
 type Graph struct {
-	nodes NodeList
-	edges EdgeList
+	nodes      NodeList
+	edges      EdgeList
+	attributes map[Node]Attributes
+}
+
+// Duration uses the duration attribute of a step where there is one.
+func (g *Graph) Duration(fallback DurationFunc) DurationFunc {
+	return func(n Node) int {
+		if d, err := strconv.Atoi(g.attributes[n]["duration"]); err == nil {
+			return d
+		}
+		return fallback(n)
+	}
+}
+
+// Skill returns the skill attribute of a step, if any.
+func (g *Graph) Skill(n Node) string {
+	return g.attributes[n]["skill"]
+}
+
+// Successors returns, for every node, the nodes that depend on it.
+func (g *Graph) Successors() map[Node]NodeList {
+	successors := make(map[Node]NodeList, len(g.nodes))
+	for _, e := range g.edges {
+		successors[e.start] = append(successors[e.start], e.end)
+	}
+	return successors
+}
+
+// InDegrees returns the number of nodes every node depends on.
+func (g *Graph) InDegrees() map[Node]int {
+	inDegree := make(map[Node]int, len(g.nodes))
+	for _, n := range g.nodes {
+		inDegree[n] = 0
+	}
+	for _, e := range g.edges {
+		inDegree[e.end]++
+	}
+	return inDegree
 }
 
 func loadData(filename string) *Graph {
@@ -64,50 +123,125 @@ func loadData(filename string) *Graph {
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	var v1, v2 Node
-	edges := make(EdgeList, 0)
-	nodes := make(map[Node]bool)
-	for scanner.Scan() {
-		if _, err := fmt.Sscanf(scanner.Text(),
-			"Step %c must be finished before step %c can begin.", &v1, &v2); err != nil {
-			log.Fatal(err)
-		}
-		edges = append(edges, DirectedEdge{v1, v2})
-		nodes[v1] = true
-		nodes[v2] = true
-	}
-	nodeList := make(NodeList, 0)
-	for k := range nodes {
-		nodeList = append(nodeList, k)
+	graph, err := parseGraph(file)
+	if err != nil {
+		log.Fatal(err)
 	}
-	return &Graph{nodeList, edges}
+	return graph
 }
 
-// Prioritized topological sort.
-func topologicalSort(graph *Graph) (NodeList, error) {
-	inDegree := make(map[Node]int, len(graph.nodes))
-	for _, n := range graph.nodes {
-		inDegree[n] = 0
+var (
+	sentencePattern = regexp.MustCompile(`^Step (\S+) must be finished before step (\S+) can begin\.$`)
+	stepPattern     = regexp.MustCompile(`^([^\s,\[\]]+)\s*(?:\[([^\]]*)\])?$`)
+)
+
+// parseGraph reads one instruction per line, in either of two forms:
+//
+//	Step C must be finished before step A can begin.
+//	C -> A, F
+//
+// In the second form every step may carry attributes, as in
+// build[duration=30, skill=go] -> test, and a step can be declared on its
+// own. Blank lines and lines starting with # are skipped.
+func parseGraph(r io.Reader) (*Graph, error) {
+	graph := &Graph{make(NodeList, 0), make(EdgeList, 0), map[Node]Attributes{}}
+	seen := map[Node]bool{}
+	add := func(n Node) {
+		if !seen[n] {
+			seen[n] = true
+			graph.nodes = append(graph.nodes, n)
+		}
 	}
 
-	// Store lists of edges nodes in a map.
-	adjacency := make(map[Node]NodeList, len(graph.nodes))
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if m := sentencePattern.FindStringSubmatch(line); m != nil {
+			add(Node(m[1]))
+			add(Node(m[2]))
+			graph.edges = append(graph.edges, DirectedEdge{Node(m[1]), Node(m[2])})
+			continue
+		}
+
+		parts := strings.SplitN(line, "->", 2)
+		start, err := parseStep(graph, parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", lineNumber, err)
+		}
+		add(start)
+		if len(parts) == 1 {
+			continue
+		}
+		for _, item := range splitSteps(parts[1]) {
+			end, err := parseStep(graph, item)
+			if err != nil {
+				return nil, fmt.Errorf("line %v: %v", lineNumber, err)
+			}
+			add(end)
+			graph.edges = append(graph.edges, DirectedEdge{start, end})
+		}
+	}
+	return graph, scanner.Err()
+}
 
-	for _, e := range graph.edges {
-		if _, ok := adjacency[e.start]; !ok {
-			adjacency[e.start] = NodeList{e.end}
-		} else {
-			adjacency[e.start] = append(adjacency[e.start], e.end)
+// splitSteps splits a list of steps on the commas outside of attributes.
+func splitSteps(s string) []string {
+	items := make([]string, 0)
+	depth, from := 0, 0
+	for i, c := range s {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, s[from:i])
+				from = i + 1
+			}
 		}
 	}
+	return append(items, s[from:])
+}
 
-	// Initialize in-degree for each node.
-	for _, v := range adjacency {
-		for _, v2 := range v {
-			inDegree[v2]++
+// parseStep parses a step name with optional attributes and records the
+// attributes in the graph.
+func parseStep(graph *Graph, s string) (Node, error) {
+	m := stepPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return "", fmt.Errorf("cannot parse step %q", strings.TrimSpace(s))
+	}
+	n := Node(m[1])
+	if strings.TrimSpace(m[2]) == "" {
+		return n, nil
+	}
+	for _, attribute := range strings.Split(m[2], ",") {
+		kv := strings.SplitN(attribute, "=", 2)
+		if len(kv) != 2 {
+			return "", fmt.Errorf("attribute %q of step %v is not key=value", strings.TrimSpace(attribute), n)
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if key == "duration" {
+			if _, err := strconv.Atoi(value); err != nil {
+				return "", fmt.Errorf("duration %q of step %v is not a number", value, n)
+			}
+		}
+		if graph.attributes[n] == nil {
+			graph.attributes[n] = Attributes{}
 		}
+		graph.attributes[n][key] = value
 	}
+	return n, nil
+}
+
+// Prioritized topological sort.
+func topologicalSort(graph *Graph) (NodeList, error) {
+	inDegree := graph.InDegrees()
+	adjacency := graph.Successors()
 
 	// Enqueue all vertices with in-degree of 0.
 	queue := make(NodeList, 0)
@@ -141,7 +275,7 @@ func topologicalSort(graph *Graph) (NodeList, error) {
 	}
 
 	if count != len(graph.nodes) {
-		return nil, errors.New("cycle detected")
+		return nil, newCycleError(graph)
 	}
 
 	return order, nil
@@ -156,165 +290,78 @@ func part1(graph *Graph) {
 	fmt.Println(order)
 }
 
-type Task struct {
-	node     Node
-	progress int
-}
-
-type Workers []*Task
-
-func (w *Workers) FirstIdleWorker() (int, error) {
-	for i, task := range *w {
-		if task == nil {
-			return i, nil
-		}
-	}
-	return -1, errors.New("no idle worker available")
-}
-
-func (w *Workers) Step() {
-	for i, task := range *w {
-		if task != nil && task.progress == 0 {
-			(*w)[i] = nil
-			continue
-		}
-		if task != nil && task.progress > 0 {
-			task.progress--
-		}
+// Prioritized topological sort with worker assignment. Steps take their
+// letter number plus timeBase seconds and all workers are alike.
+func topologicalSort2(graph *Graph, workerCount int, timeBase int) (NodeList, error) {
+	scheduler := &Scheduler{
+		Workers:  NewWorkers(workerCount),
+		Duration: LetterDuration(timeBase),
+		Policy:   Alphabetical{},
 	}
-}
-
-func (w *Workers) AllIdle() bool {
-	for _, task := range *w {
-		if task != nil {
-			return false
-		}
+	schedule, err := scheduler.Run(graph)
+	if err != nil {
+		return nil, err
 	}
-	return true
+	return schedule.Order(), nil
 }
 
-func (w *Workers) AllOccupied() bool {
-	for _, task := range *w {
-		if task == nil {
-			return false
-		}
+func part2(graph *Graph, ganttWidth int, svgFile string, dotFile string) {
+	scheduler := &Scheduler{
+		Workers:  NewWorkers(5),
+		Duration: graph.Duration(LetterDuration(60)),
+		Skill:    graph.Skill,
+		Policy:   Alphabetical{},
 	}
-	return true
-}
-
-func (w *Workers) Print(t int, order NodeList) {
-	fmt.Printf("%5v", t)
-	for _, task := range *w {
-		if task != nil {
-			fmt.Printf(" %5c", task.node)
-		} else {
-			fmt.Printf(" %5c", '.')
-		}
+	schedule, err := scheduler.Run(graph)
+	if err != nil {
+		log.Fatal(err)
 	}
-	fmt.Printf(" \"%v\"\n", order.String())
-}
-
-// Prioritized topological sort with worker assignment.
-func topologicalSort2(graph *Graph, workerCount int, timeBase int) (NodeList, error) {
-	inDegree := make(map[Node]int, len(graph.nodes))
-	for _, n := range graph.nodes {
-		inDegree[n] = 0
+	fmt.Println("\nPart 2:")
+	schedule.Print(os.Stdout)
+	if ganttWidth > 0 {
+		schedule.Gantt(os.Stdout, ganttWidth)
 	}
-
-	// Store lists of edges nodes in a map.
-	adjacency := make(map[Node]NodeList, len(graph.nodes))
-
-	for _, e := range graph.edges {
-		if _, ok := adjacency[e.start]; !ok {
-			adjacency[e.start] = NodeList{e.end}
-		} else {
-			adjacency[e.start] = append(adjacency[e.start], e.end)
+	if svgFile != "" {
+		file, err := os.Create(svgFile)
+		if err != nil {
+			log.Fatal(err)
 		}
-	}
-
-	// Initialize in-degree for each node.
-	for _, v := range adjacency {
-		for _, v2 := range v {
-			inDegree[v2]++
+		defer file.Close()
+		if err := schedule.WriteSVG(file, 1200); err != nil {
+			log.Fatal(err)
 		}
 	}
+	fmt.Println(schedule.Order().String())
+	fmt.Println("Finished after", schedule.Finish, "seconds.")
 
-	// Enqueue all vertices with in-degree of 0.
-	queue := make(NodeList, 0)
-	for k, v := range inDegree {
-		if v == 0 {
-			queue = append(queue, k)
-		}
+	analysis, err := Analyze(graph, scheduler.Duration)
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	// Counter of visited vertices.
-	count := 0
-
-	// Store result.
-	order := make(NodeList, 0)
-
-	t := 0
-	workers := make(Workers, workerCount)
-
-	var u Node
-	for {
-		if len(queue) == 0 && workers.AllIdle() {
-			workers.Print(t, order)
-			break
-		}
-
-		// Assign as many workers as possible.
-		for {
-			if len(queue) == 0 || workers.AllOccupied() {
-				break
-			}
-			worker, err := workers.FirstIdleWorker()
-			if err == nil && len(queue) > 0 {
-				sort.Sort(queue)
-				u, queue = queue[0], queue[1:]
-				workers[worker] = &Task{u, int(u) - 'A' + timeBase}
-			}
+	analysis.Print(os.Stdout)
+	bound := analysis.LowerBound(len(scheduler.Workers))
+	fmt.Printf("%v workers finish %v seconds after their lower bound of %v.\n",
+		len(scheduler.Workers), schedule.Finish-bound, bound)
+
+	if dotFile != "" {
+		file, err := os.Create(dotFile)
+		if err != nil {
+			log.Fatal(err)
 		}
-
-		workers.Print(t, order)
-
-		for _, task := range workers {
-			if task != nil && task.progress == 0 {
-				// Update state when task is finished.
-				order = append(order, task.node)
-				for _, n := range adjacency[task.node] {
-					inDegree[n]--
-					if inDegree[n] == 0 {
-						queue = append(queue, n)
-					}
-				}
-				count++
-			}
+		defer file.Close()
+		if err := graph.WriteDOT(file, schedule, analysis); err != nil {
+			log.Fatal(err)
 		}
-
-		workers.Step()
-
-		t++
-	}
-
-	if count != len(graph.nodes) {
-		return nil, errors.New("cycle detected")
 	}
-
-	return order, nil
-}
-
-func part2(graph *Graph) {
-	order, err := topologicalSort2(graph, 5, 60)
-	if err != nil {
-		log.Fatal(err)
-	}
-	fmt.Println("\nPart 2:")
-	fmt.Println(order.String())
 }
 
 func main() {
+	ganttWidth := flag.Int("gantt", 0, "print the schedule as a Gantt chart this many columns wide")
+	svgFile := flag.String("svg", "", "write the schedule as an SVG Gantt chart to this file")
+	dotFile := flag.String("dot", "", "write the step graph as DOT to this file")
+	flag.Parse()
+
 	graph := loadData("input.txt")
 	part1(graph)
-	part2(graph)
+	part2(graph, *ganttWidth, *svgFile, *dotFile)
 }
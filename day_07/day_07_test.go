@@ -1,6 +1,9 @@
 package main
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestTopologicalSort(t *testing.T) {
 	graph := loadData("test_input.txt")
@@ -23,3 +26,205 @@ func TestTopologicalSort2(t *testing.T) {
 		t.Errorf("Wrong sort order, expected CABFDE, got %v.", nodes.String())
 	}
 }
+
+func TestScheduler(t *testing.T) {
+	graph := loadData("test_input.txt")
+	scheduler := &Scheduler{NewWorkers(2), LetterDuration(0), nil, Alphabetical{}}
+	schedule, err := scheduler.Run(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schedule.Finish != 15 {
+		t.Errorf("Expected to finish after 15 seconds, got %v.", schedule.Finish)
+	}
+	for _, a := range schedule.Assignments {
+		if a.Node == "F" && (a.Worker != 1 || a.Start != 3 || a.End != 9) {
+			t.Errorf("Expected F on worker 2 from 3 to 9, got %+v.", a)
+		}
+	}
+}
+
+func TestSchedulerPolicies(t *testing.T) {
+	graph := loadData("test_input.txt")
+	duration := DurationTable(map[Node]int{"B": 10}, LetterDuration(0))
+
+	alphabetical := &Scheduler{NewWorkers(1), duration, nil, Alphabetical{}}
+	longest := &Scheduler{NewWorkers(1), duration, nil, LongestFirst{duration}}
+	critical := &Scheduler{NewWorkers(2), duration, nil, NewCriticalPathFirst(graph, duration)}
+	expected := map[*Scheduler]string{
+		alphabetical: "CABDFE",
+		longest:      "CFABDE",
+		critical:     "CAFDBE",
+	}
+	for scheduler, order := range expected {
+		schedule, err := scheduler.Run(graph)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if schedule.Order().String() != order {
+			t.Errorf("Expected %v, got %v.", order, schedule.Order())
+		}
+	}
+}
+
+func TestSchedulerSkills(t *testing.T) {
+	graph := loadData("test_input.txt")
+	workers := []Worker{{"generalist", nil}, {"welder", []string{"welding"}}}
+	skill := func(n Node) string {
+		if n == "D" || n == "E" {
+			return "welding"
+		}
+		return ""
+	}
+	scheduler := &Scheduler{workers, LetterDuration(0), skill, Alphabetical{}}
+	schedule, err := scheduler.Run(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range schedule.Assignments {
+		if (a.Node == "D" || a.Node == "E") && a.Worker != 1 {
+			t.Errorf("Expected %v to be done by the welder, got %+v.", a.Node, a)
+		}
+	}
+
+	scheduler.Workers = workers[:1]
+	if _, err := scheduler.Run(graph); err == nil {
+		t.Error("Expected an error without a welder.")
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	graph := loadData("test_input.txt")
+	a, err := Analyze(graph, LetterDuration(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Length != 14 || a.TotalWork != 21 {
+		t.Errorf("Expected length 14 and total work 21, got %v and %v.", a.Length, a.TotalWork)
+	}
+	slack := map[Node]int{"C": 0, "A": 1, "B": 3, "D": 1, "F": 0, "E": 0}
+	for n, s := range slack {
+		if a.Steps[n].Slack != s {
+			t.Errorf("Expected slack %v for %v, got %+v.", s, n, a.Steps[n])
+		}
+	}
+	if len(a.CriticalPaths) != 1 || a.CriticalPaths[0].String() != "CFE" {
+		t.Errorf("Expected critical path CFE, got %v.", a.CriticalPaths)
+	}
+	if b := a.LowerBound(1); b != 21 {
+		t.Errorf("Expected a lower bound of 21 for a single worker, got %v.", b)
+	}
+}
+
+func TestGantt(t *testing.T) {
+	graph := loadData("test_input.txt")
+	scheduler := &Scheduler{NewWorkers(2), LetterDuration(0), nil, Alphabetical{}}
+	schedule, err := scheduler.Run(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	schedule.Gantt(&b, 100)
+	lines := strings.Split(b.String(), "\n")
+	if lines[2] != "1 C==AB=D===E====" || lines[3] != "2 ...F=====......" {
+		t.Errorf("Unexpected chart:\n%v", b.String())
+	}
+}
+
+func TestCycleError(t *testing.T) {
+	graph := loadData("test_input.txt")
+	graph.edges = append(graph.edges, DirectedEdge{"F", "C"}, DirectedEdge{"E", "D"})
+
+	_, err := topologicalSort(graph)
+	cycle, ok := err.(*CycleError)
+	if !ok {
+		t.Fatalf("Expected a CycleError, got %v.", err)
+	}
+	if cycle.Cycle.String() != "CFC" {
+		t.Errorf("Expected cycle C -> F -> C, got %v.", cycle.Cycle)
+	}
+	if len(cycle.Components) != 2 ||
+		cycle.Components[0].String() != "CF" ||
+		cycle.Components[1].String() != "DE" {
+		t.Errorf("Expected components CF and DE, got %v.", cycle.Components)
+	}
+	expected := "cycle detected: C -> F -> C; strongly connected: C, F; strongly connected: D, E"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q.", expected, err.Error())
+	}
+
+	scheduler := &Scheduler{NewWorkers(2), LetterDuration(0), nil, Alphabetical{}}
+	if _, err := scheduler.Run(graph); err == nil || err.Error() != expected {
+		t.Errorf("Expected %q from the scheduler, got %v.", expected, err)
+	}
+}
+
+func TestParseGraph(t *testing.T) {
+	input := `# A small build.
+Step fetch must be finished before step compile can begin.
+compile[duration=30, skill=go] -> test[skill=qa], lint
+lint -> package
+test -> package
+package [duration=2]
+`
+	graph, err := parseGraph(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.nodes) != 5 || len(graph.edges) != 5 {
+		t.Errorf("Expected 5 steps and 5 edges, got %v and %v.", graph.nodes, graph.edges)
+	}
+	duration := graph.Duration(func(Node) int { return 1 })
+	if duration("compile") != 30 || duration("package") != 2 || duration("lint") != 1 {
+		t.Errorf("Wrong durations %v, %v, %v.", duration("compile"), duration("package"), duration("lint"))
+	}
+	if graph.Skill("test") != "qa" || graph.Skill("fetch") != "" {
+		t.Errorf("Wrong skills %q and %q.", graph.Skill("test"), graph.Skill("fetch"))
+	}
+
+	order, err := topologicalSort(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.String() != "fetch,compile,lint,test,package" {
+		t.Errorf("Expected fetch,compile,lint,test,package, got %v.", order)
+	}
+	if mixed := (NodeList{"A", "bc", "D"}); mixed.String() != "A,bc,D" {
+		t.Errorf("Expected A,bc,D, got %v.", mixed)
+	}
+
+	for _, bad := range []string{"a -> b[duration=soon]", "a -> b[skill]", "a b -> c"} {
+		if _, err := parseGraph(strings.NewReader(bad)); err == nil {
+			t.Errorf("Expected an error for %q.", bad)
+		}
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	graph := loadData("test_input.txt")
+	duration := LetterDuration(0)
+	schedule, err := (&Scheduler{NewWorkers(2), duration, nil, Alphabetical{}}).Run(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analysis, err := Analyze(graph, duration)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := graph.WriteDOT(&b, schedule, analysis); err != nil {
+		t.Fatal(err)
+	}
+	dot := b.String()
+	for _, line := range []string{
+		`"C" [label="C\n#0\nt=0, worker 1"];`,
+		`"E" [label="E\n#5\nt=10, worker 1"];`,
+		`"C" -> "F" [color=red, penwidth=2];`,
+		`"C" -> "A";`,
+	} {
+		if !strings.Contains(dot, "\t"+line+"\n") {
+			t.Errorf("Expected %v in\n%v", line, dot)
+		}
+	}
+}
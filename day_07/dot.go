@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// WriteDOT writes the graph for Graphviz. Every step is labelled with its
+// index in the topological order. With a schedule the start time and worker
+// of each step are added, and with an analysis the edges on a critical path
+// are drawn in red. Both may be nil.
+func (g *Graph) WriteDOT(w io.Writer, schedule *Schedule, analysis *Analysis) error {
+	order, err := topologicalSort(g)
+	if err != nil {
+		return err
+	}
+
+	assignments := map[Node]Assignment{}
+	if schedule != nil {
+		for _, a := range schedule.Assignments {
+			assignments[a.Node] = a
+		}
+	}
+	critical := map[DirectedEdge]bool{}
+	if analysis != nil {
+		for _, path := range analysis.CriticalPaths {
+			for i := 1; i < len(path); i++ {
+				critical[DirectedEdge{path[i-1], path[i]}] = true
+			}
+		}
+	}
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "digraph steps {")
+	fmt.Fprintln(b, "\trankdir=LR;")
+	for i, n := range order {
+		text := fmt.Sprintf("%v\n#%v", n, i)
+		if a, ok := assignments[n]; ok {
+			text += fmt.Sprintf("\nt=%v, worker %v", a.Start, schedule.Workers[a.Worker].Name)
+		}
+		fmt.Fprintf(b, "\t%v [label=%v];\n", strconv.Quote(string(n)), strconv.Quote(text))
+	}
+	for _, e := range g.edges {
+		style := ""
+		if critical[e] {
+			style = " [color=red, penwidth=2]"
+		}
+		fmt.Fprintf(b, "\t%v -> %v%v;\n", strconv.Quote(string(e.start)), strconv.Quote(string(e.end)), style)
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// Gantt writes the schedule as a text chart of at most width columns, one
+// lane per worker. A bar starts with the step name and is filled with '=',
+// idle time is shaded with '.'.
+func (s *Schedule) Gantt(w io.Writer, width int) {
+	scale := max(1, (s.Finish+width-1)/max(width, 1))
+	columns := (s.Finish + scale - 1) / scale
+
+	nameWidth := 0
+	for _, worker := range s.Workers {
+		nameWidth = max(nameWidth, len(worker.Name))
+	}
+
+	axis := []byte(strings.Repeat(" ", columns+1))
+	for c := 0; c <= columns; c += 10 {
+		axis[c] = '|'
+	}
+	fmt.Fprintf(w, "%*v %v\n", nameWidth, "", string(axis))
+	fmt.Fprintf(w, "%*v 0 (%v seconds per column, %v seconds in total)\n", nameWidth, "", scale, s.Finish)
+
+	for i, worker := range s.Workers {
+		lane := []rune(strings.Repeat(".", columns))
+		for _, a := range s.Assignments {
+			if a.Worker != i || a.End == a.Start {
+				continue
+			}
+			first, last := a.Start/scale, (a.End-1)/scale
+			name := []rune(string(a.Node))
+			for c := first; c <= last; c++ {
+				if k := c - first; k < len(name) {
+					lane[c] = name[k]
+				} else {
+					lane[c] = '='
+				}
+			}
+		}
+		fmt.Fprintf(w, "%*v %v\n", nameWidth, worker.Name, string(lane))
+	}
+}
+
+// WriteSVG writes the schedule as an SVG Gantt chart. Every worker is a
+// lane shaded grey where it is idle, with a labelled bar per step.
+func (s *Schedule) WriteSVG(w io.Writer, width int) error {
+	const (
+		laneHeight = 28
+		margin     = 60
+		axisHeight = 24
+	)
+	pixels := float64(width-margin-10) / float64(max(s.Finish, 1))
+	x := func(t int) float64 { return margin + float64(t)*pixels }
+	height := axisHeight + laneHeight*len(s.Workers) + 10
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%v\" height=\"%v\" font-family=\"sans-serif\" font-size=\"12\">\n", width, height)
+
+	step := 1
+	for s.Finish/step > 10 {
+		step *= 10
+	}
+	for t := 0; t <= s.Finish; t += step {
+		fmt.Fprintf(b, "  <line x1=\"%.1f\" y1=\"%v\" x2=\"%.1f\" y2=\"%v\" stroke=\"#ccc\"/>\n",
+			x(t), axisHeight-6, x(t), height-10)
+		fmt.Fprintf(b, "  <text x=\"%.1f\" y=\"%v\" text-anchor=\"middle\">%v</text>\n", x(t), axisHeight-10, t)
+	}
+
+	for i, worker := range s.Workers {
+		y := axisHeight + i*laneHeight
+		fmt.Fprintf(b, "  <text x=\"%v\" y=\"%v\">%v</text>\n", 4, y+laneHeight/2+4, html.EscapeString(worker.Name))
+		fmt.Fprintf(b, "  <rect x=\"%.1f\" y=\"%v\" width=\"%.1f\" height=\"%v\" fill=\"#e4e4e4\"/>\n",
+			x(0), y+2, x(s.Finish)-x(0), laneHeight-4)
+		for _, a := range s.Assignments {
+			if a.Worker != i {
+				continue
+			}
+			fmt.Fprintf(b, "  <rect x=\"%.1f\" y=\"%v\" width=\"%.1f\" height=\"%v\" fill=\"#4a7fc1\" stroke=\"#fff\">"+
+				"<title>%v: %v-%v</title></rect>\n",
+				x(a.Start), y+2, x(a.End)-x(a.Start), laneHeight-4,
+				html.EscapeString(string(a.Node)), a.Start, a.End)
+			fmt.Fprintf(b, "  <text x=\"%.1f\" y=\"%v\" text-anchor=\"middle\" fill=\"#fff\">%v</text>\n",
+				(x(a.Start)+x(a.End))/2, y+laneHeight/2+4, html.EscapeString(string(a.Node)))
+		}
+	}
+	fmt.Fprintln(b, "</svg>")
+	return b.Flush()
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// DurationFunc returns the number of seconds a step takes.
+type DurationFunc func(n Node) int
+
+// LetterDuration is the duration of the puzzle: A takes base+1 seconds, B
+// base+2 and so on. Longer names count by their first letter.
+func LetterDuration(base int) DurationFunc {
+	return func(n Node) int {
+		if n == "" {
+			return base
+		}
+		return int(n[0]) - 'A' + 1 + base
+	}
+}
+
+// DurationTable looks steps up in a table and falls back to another duration
+// for steps that are not in it.
+func DurationTable(table map[Node]int, fallback DurationFunc) DurationFunc {
+	return func(n Node) int {
+		if d, ok := table[n]; ok {
+			return d
+		}
+		return fallback(n)
+	}
+}
+
+// Worker can perform any step that requires no skill, and the steps that
+// require one of its skills.
+type Worker struct {
+	Name   string
+	Skills []string
+}
+
+func (w Worker) Can(skill string) bool {
+	if skill == "" {
+		return true
+	}
+	for _, s := range w.Skills {
+		if s == skill {
+			return true
+		}
+	}
+	return false
+}
+
+// NewWorkers returns identical workers without any skills.
+func NewWorkers(count int) []Worker {
+	workers := make([]Worker, count)
+	for i := range workers {
+		workers[i] = Worker{Name: fmt.Sprint(i + 1)}
+	}
+	return workers
+}
+
+// Policy decides which ready step is handed out first.
+type Policy interface {
+	Less(a, b Node) bool
+}
+
+type Alphabetical struct{}
+
+func (Alphabetical) Less(a, b Node) bool {
+	return a < b
+}
+
+// LongestFirst prefers the longest steps, alphabetically on ties.
+type LongestFirst struct {
+	Duration DurationFunc
+}
+
+func (p LongestFirst) Less(a, b Node) bool {
+	if da, db := p.Duration(a), p.Duration(b); da != db {
+		return da > db
+	}
+	return a < b
+}
+
+// CriticalPathFirst prefers the steps with the longest chain of work still
+// depending on them, alphabetically on ties.
+type CriticalPathFirst struct {
+	remaining map[Node]int
+}
+
+func NewCriticalPathFirst(graph *Graph, duration DurationFunc) CriticalPathFirst {
+	return CriticalPathFirst{remainingWork(graph, duration)}
+}
+
+func (p CriticalPathFirst) Less(a, b Node) bool {
+	if ra, rb := p.remaining[a], p.remaining[b]; ra != rb {
+		return ra > rb
+	}
+	return a < b
+}
+
+// remainingWork returns for every node the length of the longest path from
+// its start to the end of the graph, the node itself included. Nodes on a
+// cycle are left out.
+func remainingWork(graph *Graph, duration DurationFunc) map[Node]int {
+	order, _ := topologicalSort(graph)
+	successors := graph.Successors()
+	remaining := make(map[Node]int, len(order))
+	for i := len(order) - 1; i >= 0; i-- {
+		n := order[i]
+		longest := 0
+		for _, s := range successors[n] {
+			longest = max(longest, remaining[s])
+		}
+		remaining[n] = duration(n) + longest
+	}
+	return remaining
+}
+
+type readyQueue struct {
+	nodes  NodeList
+	policy Policy
+}
+
+func (q *readyQueue) Len() int           { return len(q.nodes) }
+func (q *readyQueue) Less(i, j int) bool { return q.policy.Less(q.nodes[i], q.nodes[j]) }
+func (q *readyQueue) Swap(i, j int)      { q.nodes[i], q.nodes[j] = q.nodes[j], q.nodes[i] }
+func (q *readyQueue) Push(x interface{}) { q.nodes = append(q.nodes, x.(Node)) }
+func (q *readyQueue) Pop() interface{} {
+	n := q.nodes[len(q.nodes)-1]
+	q.nodes = q.nodes[:len(q.nodes)-1]
+	return n
+}
+
+// Scheduler hands out the steps of a graph to a pool of workers. Whenever a
+// worker is idle it takes the first ready step, by policy, that it is able
+// to perform. Skill may be nil when no step needs a skill.
+type Scheduler struct {
+	Workers  []Worker
+	Duration DurationFunc
+	Skill    func(n Node) string
+	Policy   Policy
+}
+
+// Assignment is a step performed by a worker, from Start up to End.
+type Assignment struct {
+	Node   Node
+	Worker int
+	Start  int
+	End    int
+}
+
+type Schedule struct {
+	Workers     []Worker
+	Assignments []Assignment
+	Finish      int
+}
+
+func (s *Scheduler) skill(n Node) string {
+	if s.Skill == nil {
+		return ""
+	}
+	return s.Skill(n)
+}
+
+func (s *Scheduler) Run(graph *Graph) (*Schedule, error) {
+	successors := graph.Successors()
+	inDegree := graph.InDegrees()
+
+	ready := &readyQueue{make(NodeList, 0), s.Policy}
+	for _, n := range graph.nodes {
+		if inDegree[n] == 0 {
+			heap.Push(ready, n)
+		}
+	}
+
+	schedule := &Schedule{Workers: s.Workers, Assignments: make([]Assignment, 0, len(graph.nodes))}
+	busy := make([]bool, len(s.Workers))
+	running := make([]int, 0, len(s.Workers))
+	t := 0
+	for done := 0; done < len(graph.nodes); {
+		// Assign as many ready steps as possible.
+		deferred := make(NodeList, 0)
+		for ready.Len() > 0 && len(running) < len(s.Workers) {
+			n := heap.Pop(ready).(Node)
+			worker := -1
+			for i, w := range s.Workers {
+				if !busy[i] && w.Can(s.skill(n)) {
+					worker = i
+					break
+				}
+			}
+			if worker < 0 {
+				deferred = append(deferred, n)
+				continue
+			}
+			busy[worker] = true
+			running = append(running, len(schedule.Assignments))
+			schedule.Assignments = append(schedule.Assignments, Assignment{n, worker, t, t + s.Duration(n)})
+		}
+		for _, n := range deferred {
+			heap.Push(ready, n)
+		}
+
+		if len(running) == 0 {
+			if ready.Len() > 0 {
+				return nil, fmt.Errorf("no worker can perform step %v", ready.nodes[0])
+			}
+			return nil, newCycleError(graph)
+		}
+
+		// Advance to the next finished step.
+		t = schedule.Assignments[running[0]].End
+		for _, i := range running {
+			t = min(t, schedule.Assignments[i].End)
+		}
+		still := running[:0]
+		for _, i := range running {
+			a := schedule.Assignments[i]
+			if a.End > t {
+				still = append(still, i)
+				continue
+			}
+			busy[a.Worker] = false
+			done++
+			for _, n := range successors[a.Node] {
+				inDegree[n]--
+				if inDegree[n] == 0 {
+					heap.Push(ready, n)
+				}
+			}
+		}
+		running = still
+	}
+	schedule.Finish = t
+	return schedule, nil
+}
+
+// Order returns the steps in the order they were finished, by worker on
+// ties.
+func (s *Schedule) Order() NodeList {
+	finished := make([]Assignment, len(s.Assignments))
+	copy(finished, s.Assignments)
+	sort.SliceStable(finished, func(i, j int) bool {
+		if finished[i].End != finished[j].End {
+			return finished[i].End < finished[j].End
+		}
+		return finished[i].Worker < finished[j].Worker
+	})
+	order := make(NodeList, len(finished))
+	for i, a := range finished {
+		order[i] = a.Node
+	}
+	return order
+}
+
+func (s *Schedule) Print(w io.Writer) {
+	fmt.Fprintf(w, "%6v %8v %6v %6v\n", "Step", "Worker", "Start", "End")
+	for _, a := range s.Assignments {
+		fmt.Fprintf(w, "%6v %8v %6v %6v\n", a.Node, s.Workers[a.Worker].Name, a.Start, a.End)
+	}
+}
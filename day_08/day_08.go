@@ -2,8 +2,11 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"os"
 	"strconv"
 	"strings"
@@ -14,84 +17,369 @@ type Node struct {
 	metadata []int
 }
 
-func (n *Node) MetadataSum() int {
-	sum := 0
-	for _, child := range n.children {
-		sum += child.MetadataSum()
+// walk calls visit for every node of the tree, children before their
+// parent. It keeps its own stack, so the depth of the tree is not limited by
+// the goroutine stack.
+func (n *Node) walk(visit func(n *Node)) {
+	type frame struct {
+		node  *Node
+		child int
 	}
-	for _, data := range n.metadata {
-		sum += data
+	stack := []frame{{n, 0}}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.child < len(top.node.children) {
+			top.child++
+			stack = append(stack, frame{top.node.children[top.child-1], 0})
+			continue
+		}
+		visit(top.node)
+		stack = stack[:len(stack)-1]
 	}
-	return sum
 }
 
-func (n *Node) Value() int {
+// subtreeSums returns the metadata sum of every subtree.
+func (n *Node) subtreeSums() map[*Node]int {
+	sums := map[*Node]int{}
+	n.walk(func(node *Node) {
+		sum := 0
+		for _, child := range node.children {
+			sum += sums[child]
+		}
+		for _, data := range node.metadata {
+			sum += data
+		}
+		sums[node] = sum
+	})
+	return sums
+}
+
+// values returns the value of every node.
+func (n *Node) values() map[*Node]int {
+	values := map[*Node]int{}
+	n.walk(func(node *Node) {
+		sum := 0
+		if len(node.children) == 0 {
+			for _, data := range node.metadata {
+				sum += data
+			}
+		}
+		for _, childIndex := range node.metadata {
+			if len(node.children) > 0 && childIndex > 0 && childIndex <= len(node.children) {
+				sum += values[node.children[childIndex-1]]
+			}
+		}
+		values[node] = sum
+	})
+	return values
+}
+
+func (n *Node) MetadataSum() int {
 	sum := 0
-	if len(n.children) == 0 {
-		for _, data := range n.metadata {
+	n.walk(func(node *Node) {
+		for _, data := range node.metadata {
 			sum += data
 		}
-		return sum
+	})
+	return sum
+}
+
+func (n *Node) Value() int {
+	return n.values()[n]
+}
+
+// Serialize writes the tree back in the flat number format it was parsed
+// from.
+func (n *Node) Serialize(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	first := true
+	write := func(v int) {
+		if !first {
+			b.WriteByte(' ')
+		}
+		first = false
+		b.WriteString(strconv.Itoa(v))
 	}
-	for _, childIndex := range n.metadata {
-		if childIndex > 0 && childIndex <= len(n.children) {
-			sum += n.children[childIndex-1].Value()
+
+	type frame struct {
+		node  *Node
+		child int
+	}
+	write(len(n.children))
+	write(len(n.metadata))
+	stack := []frame{{n, 0}}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.child < len(top.node.children) {
+			child := top.node.children[top.child]
+			top.child++
+			write(len(child.children))
+			write(len(child.metadata))
+			stack = append(stack, frame{child, 0})
+			continue
+		}
+		for _, data := range top.node.metadata {
+			write(data)
 		}
+		stack = stack[:len(stack)-1]
 	}
-	return sum
+	b.WriteByte('\n')
+	return b.Flush()
 }
 
-func loadData(filename string) []int {
-	f, err := os.Open(filename)
+// Print writes the tree indented by depth. Every node is shown with its
+// path, as accepted by Select, its metadata, metadata sum and value.
+func (n *Node) Print(w io.Writer) {
+	sums := n.subtreeSums()
+	values := n.values()
+
+	type frame struct {
+		node  *Node
+		path  string
+		depth int
+	}
+	stack := []frame{{n, "", 0}}
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		path := f.path
+		if path == "" {
+			path = "/"
+		}
+		fmt.Fprintf(w, "%v%v metadata=%v sum=%v value=%v\n",
+			strings.Repeat("  ", f.depth), path, f.node.metadata, sums[f.node], values[f.node])
+		for i := len(f.node.children) - 1; i >= 0; i-- {
+			childPath := strconv.Itoa(i)
+			if f.path != "" {
+				childPath = f.path + "/" + childPath
+			}
+			stack = append(stack, frame{f.node.children[i], childPath, f.depth + 1})
+		}
+	}
+}
+
+// Select returns the node at a path of child indices counting from 0, such
+// as 0/2/1. An empty path or / selects the node itself.
+func (n *Node) Select(path string) (*Node, error) {
+	node := n
+	for _, part := range strings.Split(strings.Trim(path, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		i, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("path %q: %q is not a child index", path, part)
+		}
+		if i < 0 || i >= len(node.children) {
+			return nil, fmt.Errorf("path %q: no child %v, the node has %v children", path, i, len(node.children))
+		}
+		node = node.children[i]
+	}
+	return node, nil
+}
+
+// ParseError is a structural problem in the license file. Offset is the
+// index of the number, counting from 0, where the problem was found.
+type ParseError struct {
+	Offset  int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("token %v: %v", e.Offset, e.Message)
+}
+
+// tokens is a source of numbers, one at a time. Next returns io.EOF after
+// the last number.
+type tokens interface {
+	Next() (int, error)
+	Offset() int
+}
+
+// readerTokens reads whitespace separated numbers from a reader without
+// loading the whole input.
+type readerTokens struct {
+	r      *bufio.Reader
+	offset int
+}
+
+func newReaderTokens(r io.Reader) *readerTokens {
+	return &readerTokens{bufio.NewReader(r), 0}
+}
+
+func (t *readerTokens) Offset() int {
+	return t.offset
+}
+
+func (t *readerTokens) Next() (int, error) {
+	var c byte
+	var err error
+	for {
+		if c, err = t.r.ReadByte(); err != nil {
+			return 0, err
+		}
+		if c != ' ' && c != '\n' && c != '\r' && c != '\t' {
+			break
+		}
+	}
+
+	value := 0
+	for {
+		if c < '0' || c > '9' {
+			return 0, &ParseError{t.offset, fmt.Sprintf("unexpected character %q", c)}
+		}
+		d := int(c - '0')
+		if value > (math.MaxInt-d)/10 {
+			return 0, &ParseError{t.offset, "number out of range"}
+		}
+		value = value*10 + d
+		if c, err = t.r.ReadByte(); err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+		if c == ' ' || c == '\n' || c == '\r' || c == '\t' {
+			break
+		}
+	}
+	t.offset++
+	return value, nil
+}
+
+type sliceTokens struct {
+	values []int
+	offset int
+}
+
+func (t *sliceTokens) Offset() int {
+	return t.offset
+}
+
+func (t *sliceTokens) Next() (int, error) {
+	if t.offset >= len(t.values) {
+		return 0, io.EOF
+	}
+	t.offset++
+	return t.values[t.offset-1], nil
+}
+
+// parseTree reads one tree from the tokens with an explicit stack, so trees
+// of any depth can be parsed.
+func parseTree(t tokens) (*Node, error) {
+	next := func(what string) (int, error) {
+		value, err := t.Next()
+		if err == io.EOF {
+			return 0, &ParseError{t.Offset(), "unexpected end of input, expected " + what}
+		}
+		return value, err
+	}
+	// The counts come straight from the input, so they are not trusted to
+	// size anything up front.
+	header := func() (*Node, int, int, error) {
+		childCount, err := next("child count")
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		metadataCount, err := next("metadata count")
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		return &Node{}, childCount, metadataCount, nil
+	}
+
+	type frame struct {
+		node          *Node
+		childrenLeft  int
+		metadataCount int
+	}
+	root, childCount, metadataCount, err := header()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	defer f.Close()
-	fInfo, err := f.Stat()
+	stack := []frame{{root, childCount, metadataCount}}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.childrenLeft > 0 {
+			top.childrenLeft--
+			child, childCount, metadataCount, err := header()
+			if err != nil {
+				return nil, err
+			}
+			top.node.children = append(top.node.children, child)
+			stack = append(stack, frame{child, childCount, metadataCount})
+			continue
+		}
+		for j := 0; j < top.metadataCount; j++ {
+			data, err := next("metadata")
+			if err != nil {
+				return nil, err
+			}
+			top.node.metadata = append(top.node.metadata, data)
+		}
+		stack = stack[:len(stack)-1]
+	}
+	return root, nil
+}
+
+// parseReader parses a whole license file, which must hold exactly one
+// tree.
+func parseReader(r io.Reader) (*Node, error) {
+	t := newReaderTokens(r)
+	root, err := parseTree(t)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	if _, err := t.Next(); err != io.EOF {
+		if err != nil {
+			return nil, err
+		}
+		return nil, &ParseError{t.Offset() - 1, "trailing number after the root node"}
 	}
+	return root, nil
+}
 
-	r := bufio.NewReader(f)
-	buffer := make([]byte, fInfo.Size())
-	_, err = r.Read(buffer)
+func loadData(filename string) []int {
+	f, err := os.Open(filename)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer f.Close()
 
-	ts := strings.TrimSpace(string(buffer))
-	parts := strings.Split(ts, " ")
-
-	values := make([]int, 0, len(parts))
-	var value int
-	for _, p := range parts {
-		value, err = strconv.Atoi(p)
+	t := newReaderTokens(f)
+	values := make([]int, 0)
+	for {
+		value, err := t.Next()
+		if err == io.EOF {
+			return values
+		}
 		if err != nil {
 			log.Fatal(err)
 		}
 		values = append(values, value)
 	}
-
-	return values
 }
 
 func parseData(values []int, i int) (int, *Node) {
-	childCount := values[i]
-	i++
-	metadataCount := values[i]
-	i++
-	children := make([]*Node, 0, childCount)
-	var child *Node
-	for j := 0; j < childCount; j++ {
-		i, child = parseData(values, i)
-		children = append(children, child)
+	t := &sliceTokens{values, i}
+	root, err := parseTree(t)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return t.offset, root
+}
+
+func loadTree(filename string) *Node {
+	f, err := os.Open(filename)
+	if err != nil {
+		log.Fatal(err)
 	}
-	metadata := make([]int, 0, metadataCount)
-	for j := 0; j < metadataCount; j++ {
-		metadata = append(metadata, values[i])
-		i++
+	defer f.Close()
+
+	root, err := parseReader(f)
+	if err != nil {
+		log.Fatal(err)
 	}
-	return i, &Node{children, metadata}
+	return root
 }
 
 func part1(root *Node) {
@@ -105,8 +393,29 @@ func part2(root *Node) {
 }
 
 func main() {
-	values := loadData("input.txt")
-	_, root := parseData(values, 0)
+	print := flag.Bool("print", false, "print the tree with the sum and value of every node")
+	path := flag.String("select", "", "print the subtree at this path, such as 0/2/1")
+	serialize := flag.Bool("serialize", false, "write the tree back in the input format")
+	flag.Parse()
+
+	root := loadTree("input.txt")
 	part1(root)
 	part2(root)
+
+	if *path != "" {
+		node, err := root.Select(*path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Value of %v = %v\n", *path, node.Value())
+		root = node
+	}
+	if *print {
+		root.Print(os.Stdout)
+	}
+	if *serialize {
+		if err := root.Serialize(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	}
 }
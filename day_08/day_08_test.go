@@ -1,6 +1,10 @@
 package main
 
-import "testing"
+import (
+	"math"
+	"strings"
+	"testing"
+)
 
 func TestParse(t *testing.T) {
 	values := loadData("test_input.txt")
@@ -47,3 +51,92 @@ func TestPart2(t *testing.T) {
 		t.Errorf("Root value should be 66, got %v.", root.Value())
 	}
 }
+
+func TestParseReaderErrors(t *testing.T) {
+	cases := map[string]string{
+		"2 3 0 3 10 11 12 1 1 0 1 99 2 1 1":     "token 15: unexpected end of input, expected metadata",
+		"2 3 0 3 10 11 12 1 1 0 1 99 2 1 1 2 7": "token 16: trailing number after the root node",
+		"1 1 0":                                 "token 3: unexpected end of input, expected metadata count",
+		"0 1 x":                                 "token 2: unexpected character 'x'",
+		"99999999999999999 1 0 0":               "token 4: unexpected end of input, expected child count",
+		"0 99999999999999999 1":                 "token 3: unexpected end of input, expected metadata",
+		"18446744073709551616 1 5":              "token 0: number out of range",
+		"0 1 9223372036854775808":               "token 2: number out of range",
+	}
+	for input, expected := range cases {
+		_, err := parseReader(strings.NewReader(input))
+		if err == nil || err.Error() != expected {
+			t.Errorf("%v: expected %q, got %v.", input, expected, err)
+		}
+	}
+
+	root, err := parseReader(strings.NewReader("0 1 9223372036854775807"))
+	if err != nil || root.MetadataSum() != math.MaxInt {
+		t.Errorf("Expected the largest int as metadata, got %v, %v.", root, err)
+	}
+}
+
+func TestParseDeepTree(t *testing.T) {
+	const depth = 1000000
+	var b strings.Builder
+	for i := 0; i < depth; i++ {
+		b.WriteString("1 1 ")
+	}
+	b.WriteString("0 1 1")
+	for i := 0; i < depth; i++ {
+		b.WriteString(" 1")
+	}
+	root, err := parseReader(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := root.MetadataSum(); sum != depth+1 {
+		t.Errorf("Expected metadata sum %v, got %v.", depth+1, sum)
+	}
+	if value := root.Value(); value != 1 {
+		t.Errorf("Expected value 1, got %v.", value)
+	}
+}
+
+func TestSerialize(t *testing.T) {
+	input := "2 3 0 3 10 11 12 1 1 0 1 99 2 1 1 2\n"
+	root, err := parseReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := root.Serialize(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != input {
+		t.Errorf("Expected %q, got %q.", input, b.String())
+	}
+}
+
+func TestPrint(t *testing.T) {
+	_, root := parseData(loadData("test_input.txt"), 0)
+	var b strings.Builder
+	root.Print(&b)
+	expected := "/ metadata=[1 1 2] sum=138 value=66\n" +
+		"  0 metadata=[10 11 12] sum=33 value=33\n" +
+		"  1 metadata=[2] sum=101 value=0\n" +
+		"    1/0 metadata=[99] sum=99 value=99\n"
+	if b.String() != expected {
+		t.Errorf("Expected\n%v\ngot\n%v", expected, b.String())
+	}
+}
+
+func TestSelect(t *testing.T) {
+	_, root := parseData(loadData("test_input.txt"), 0)
+	if node, err := root.Select("1/0"); err != nil || node.Value() != 99 {
+		t.Errorf("Expected node D with value 99, got %v, %v.", node, err)
+	}
+	if node, err := root.Select("/"); err != nil || node != root {
+		t.Errorf("Expected the root, got %v, %v.", node, err)
+	}
+	for _, path := range []string{"2", "1/0/0", "a"} {
+		if _, err := root.Select(path); err == nil {
+			t.Errorf("Expected an error for path %v.", path)
+		}
+	}
+}
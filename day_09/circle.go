@@ -0,0 +1,106 @@
+package main
+
+// Circle is a ring of marbles stored in a fixed-size ring buffer. The current
+// marble is always at the back and clockwise runs from front to back, so
+// moving around the circle is a matter of moving marbles between the two
+// ends.
+type Circle struct {
+	marbles []int
+	head    int
+	size    int
+}
+
+// NewCircle returns a circle holding only marble 0, with room for capacity
+// marbles in total.
+func NewCircle(capacity int) *Circle {
+	c := &Circle{marbles: make([]int, max(capacity, 1))}
+	c.pushBack(0)
+	return c
+}
+
+func (c *Circle) Len() int {
+	return c.size
+}
+
+// Current returns the current marble.
+func (c *Circle) Current() int {
+	return c.marbles[c.index(c.size-1)]
+}
+
+// Marbles returns the circle clockwise, starting from the front.
+func (c *Circle) Marbles() []int {
+	marbles := make([]int, c.size)
+	for i := range marbles {
+		marbles[i] = c.marbles[c.index(i)]
+	}
+	return marbles
+}
+
+// Rotate makes the marble steps positions clockwise of the current marble
+// the new current marble. Negative steps go counter-clockwise.
+func (c *Circle) Rotate(steps int) {
+	if c.size == 0 {
+		return
+	}
+	steps %= c.size
+	for ; steps > 0; steps-- {
+		c.pushBack(c.popFront())
+	}
+	for ; steps < 0; steps++ {
+		c.pushFront(c.popBack())
+	}
+}
+
+// Insert places a marble clockwise of the current marble and makes it
+// current.
+func (c *Circle) Insert(marble int) {
+	c.pushBack(marble)
+}
+
+// Remove takes out the current marble and makes the marble clockwise of it
+// current.
+func (c *Circle) Remove() int {
+	marble := c.popBack()
+	c.Rotate(1)
+	return marble
+}
+
+func (c *Circle) index(i int) int {
+	i += c.head
+	if i >= len(c.marbles) {
+		i -= len(c.marbles)
+	}
+	return i
+}
+
+func (c *Circle) pushBack(marble int) {
+	if c.size == len(c.marbles) {
+		panic("circle is full")
+	}
+	c.marbles[c.index(c.size)] = marble
+	c.size++
+}
+
+func (c *Circle) pushFront(marble int) {
+	if c.size == len(c.marbles) {
+		panic("circle is full")
+	}
+	c.head--
+	if c.head < 0 {
+		c.head += len(c.marbles)
+	}
+	c.marbles[c.head] = marble
+	c.size++
+}
+
+func (c *Circle) popBack() int {
+	c.size--
+	return c.marbles[c.index(c.size)]
+}
+
+func (c *Circle) popFront() int {
+	marble := c.marbles[c.head]
+	c.head = c.index(1)
+	c.size--
+	return marble
+}
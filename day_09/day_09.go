@@ -2,7 +2,7 @@ package main
 
 import (
 	"bufio"
-	"container/list"
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -14,30 +14,6 @@ type Game struct {
 	highScore        int
 }
 
-func insertMarbleIntoCircle(circle *list.List, index *list.Element, marble int) *list.Element {
-	newIndex := index.Next()
-	if newIndex == nil {
-		newIndex = circle.Front()
-	}
-	circle.InsertAfter(marble, newIndex)
-	return newIndex.Next()
-}
-
-func handleMultipleOf23(circle *list.List, index *list.Element, marble int) (int, *list.Element) {
-	var newIndex = index
-	for i := 0; i < 7; i++ {
-		newIndex = newIndex.Prev()
-		if newIndex == nil {
-			newIndex = circle.Back()
-		}
-	}
-	score := newIndex.Value.(int)
-	nextIndex := newIndex.Next()
-	circle.Remove(newIndex)
-	score += marble
-	return score, nextIndex
-}
-
 func loadData(filename string, test bool) []Game {
 	f, err := os.Open(filename)
 	if err != nil {
@@ -69,37 +45,90 @@ func loadData(filename string, test bool) []Game {
 	return games
 }
 
+// Rules are the knobs of the marble game. Every Modulus-th marble is kept
+// by the player instead of being placed, and the marble RemovalOffset steps
+// counter-clockwise of the current marble is taken out of the circle. Other
+// marbles are placed InsertionOffset steps clockwise of the current marble.
+// Score gives the points for a kept marble and the one removed with it.
+type Rules struct {
+	Modulus         int
+	RemovalOffset   int
+	InsertionOffset int
+	Score           func(marble, removed int) int
+}
+
+// DefaultRules are the rules of the puzzle.
+func DefaultRules() Rules {
+	return Rules{
+		Modulus:         23,
+		RemovalOffset:   7,
+		InsertionOffset: 1,
+		Score: func(marble, removed int) int {
+			return marble + removed
+		},
+	}
+}
+
+// Validate rejects rules the game cannot be played with. A modulus of 1
+// would remove a marble every turn and empty the circle.
+func (r Rules) Validate() error {
+	if r.Modulus < 0 || r.Modulus == 1 {
+		return fmt.Errorf("modulus %v: must be 0 for no special marbles or at least 2", r.Modulus)
+	}
+	if r.Modulus > 0 && r.Score == nil {
+		return fmt.Errorf("special marbles need a scoring function")
+	}
+	return nil
+}
+
 func play(game *Game) int {
-	circle := list.New()
-	circle.PushBack(0)
-	index := circle.Back()
+	score, err := playWithRules(game, DefaultRules(), nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return score
+}
 
-	//fmt.Print("[---] ")
-	//circle.Print(0)
+// playWithRules plays a game and returns the high score. If timeline is not
+// nil every score is recorded in it.
+func playWithRules(game *Game, rules Rules, timeline *Timeline) (int, error) {
+	if err := rules.Validate(); err != nil {
+		return 0, err
+	}
+	if game.players < 1 {
+		return 0, fmt.Errorf("%v players: need at least one", game.players)
+	}
+	circle := NewCircle(game.lastMarblePoints + 1)
 	score := make([]int, game.players)
 	player := 0
-	var tempScore int
 	for i := 1; i <= game.lastMarblePoints; i++ {
-		// Special rule if marble number is a multiple of 23.
-		if i%23 == 0 {
-			tempScore, index = handleMultipleOf23(circle, index, i)
-			score[player] += tempScore
+		if rules.Modulus > 0 && i%rules.Modulus == 0 {
+			circle.Rotate(-rules.RemovalOffset)
+			score[player] += rules.Score(i, circle.Remove())
+			if timeline != nil {
+				timeline.Record(i, player, score)
+			}
 		} else {
-			index = insertMarbleIntoCircle(circle, index, i)
+			circle.Rotate(rules.InsertionOffset)
+			circle.Insert(i)
 		}
 		player = (player + 1) % game.players
 	}
-	highScore := 0
+	// Scores may be negative with other rules.
+	highScore := score[0]
 	for _, s := range score {
 		if s > highScore {
 			highScore = s
 		}
 	}
-	return highScore
+	return highScore, nil
 }
 
-func part1(games []Game) {
-	score := play(&games[0])
+func part1(games []Game, timeline *Timeline) {
+	score, err := playWithRules(&games[0], DefaultRules(), timeline)
+	if err != nil {
+		log.Fatal(err)
+	}
 	fmt.Printf("Part 1 score: %v\n", score)
 }
 
@@ -114,7 +143,29 @@ func part2(games []Game) {
 }
 
 func main() {
+	leads := flag.Bool("leads", false, "print who led the part 1 game when")
+	csvFile := flag.String("csv", "", "write every player's part 1 score over time as CSV")
+	flag.Parse()
+
 	games := loadData("input.txt", false)
-	part1(games)
+	var timeline *Timeline
+	if *leads || *csvFile != "" {
+		timeline = NewTimeline(games[0].players)
+	}
+	part1(games, timeline)
 	part2(games)
+
+	if *leads {
+		timeline.PrintLeads(os.Stdout)
+	}
+	if *csvFile != "" {
+		f, err := os.Create(*csvFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		if err := timeline.WriteCSV(f); err != nil {
+			log.Fatal(err)
+		}
+	}
 }
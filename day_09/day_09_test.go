@@ -1,111 +1,188 @@
 package main
 
 import (
-	"container/list"
+	"reflect"
+	"strings"
 	"testing"
 )
 
-func listToIntArray(l *list.List) []int {
-	a := make([]int, 0, l.Len())
-	for e := l.Front(); e != nil; e = e.Next() {
-		a = append(a, e.Value.(int))
+// fromZero returns the marbles of a circle clockwise, starting at marble 0.
+func fromZero(circle *Circle) []int {
+	marbles := circle.Marbles()
+	for marbles[0] != 0 {
+		marbles = append(marbles[1:], marbles[0])
 	}
-	return a
+	return marbles
 }
 
-func intArrayToList(a []int) *list.List {
-	l := list.New()
-	for _, i := range a {
-		l.PushBack(i)
+func TestCircleInsert(t *testing.T) {
+	circle := NewCircle(4)
+	expected := [][]int{{0, 1}, {0, 2, 1}, {0, 2, 1, 3}}
+	for i, e := range expected {
+		marble := i + 1
+		circle.Rotate(1)
+		circle.Insert(marble)
+		if circle.Current() != marble {
+			t.Errorf("Expected current marble %v, got %v.", marble, circle.Current())
+		}
+		if a := fromZero(circle); !reflect.DeepEqual(a, e) {
+			t.Errorf("Circle should be %v but is %v.", e, a)
+		}
 	}
-	return l
 }
 
-func elementOfIndex(l *list.List, idx int) *list.Element {
-	e := l.Front()
-	for i := 0; i < idx; i++ {
-		e = e.Next()
+func TestPlayGames(t *testing.T) {
+	games := loadData("test_input.txt", true)
+	var score int
+	for _, game := range games {
+		score = play(&game)
+		if game.highScore != score {
+			t.Errorf("Game %v expected highscore = %v, but got %v.", game, game.highScore, score)
+		}
 	}
-	return e
 }
 
-func TestInsertMarbleIntoCircle(t *testing.T) {
-	circle := list.New()
-	circle.PushBack(0)
-	i0 := circle.Back()
-	i1 := insertMarbleIntoCircle(circle, i0, 1)
-	if i1 != elementOfIndex(circle, 1) {
-		t.Errorf("Index of list %v is not equal to %v.", i1, elementOfIndex(circle, 1))
-		t.Errorf("%v", listToIntArray(circle))
-	}
-	if circle.Len() != 2 {
-		t.Errorf("Circle should contain 2 elements, but contains %v.", circle.Len())
-	}
-	a := listToIntArray(circle)
-	if a[0] != 0 || a[1] != 1 {
-		t.Errorf("Circle should be 0, 1 but is %v.", a)
+func TestCircle(t *testing.T) {
+	circle := NewCircle(23)
+	for i := 1; i <= 22; i++ {
+		circle.Rotate(1)
+		circle.Insert(i)
 	}
-
-	i2 := insertMarbleIntoCircle(circle, i1, 2)
-	if i2 != elementOfIndex(circle, 1) {
-		t.Error("Index of list is not correct.")
+	if circle.Current() != 22 || circle.Len() != 23 {
+		t.Errorf("Expected current marble 22 of 23, got %v of %v.", circle.Current(), circle.Len())
 	}
-	if circle.Len() != 3 {
-		t.Errorf("Circle should contain 3 elements, but contains %v.", circle.Len())
+	circle.Rotate(-7)
+	if removed := circle.Remove(); removed != 9 {
+		t.Errorf("Expected to remove marble 9 but removed %v.", removed)
 	}
-	a = listToIntArray(circle)
-	if a[0] != 0 || a[1] != 2 || a[2] != 1 {
-		t.Errorf("Circle should be 0, 2, 1 but is %v.", a)
+	if circle.Current() != 19 {
+		t.Errorf("Expected current marble 19 but got %v.", circle.Current())
 	}
-
-	i3 := insertMarbleIntoCircle(circle, i2, 3)
-	if i3 != elementOfIndex(circle, 3) {
-		t.Errorf("Index after insert should be 3 but is %v.", i3.Value)
+	expected := []int{0, 16, 8, 17, 4, 18, 19, 2, 20, 10, 21, 5, 22, 11, 1, 12, 6, 13, 3, 14, 7, 15}
+	if marbles := fromZero(circle); !reflect.DeepEqual(marbles, expected) {
+		t.Errorf("Expected circle %v but got %v.", expected, marbles)
 	}
-	if circle.Len() != 4 {
-		t.Errorf("Circle should contain 4 elements, but contains %v.", circle.Len())
+}
+
+func BenchmarkPlay(b *testing.B) {
+	game := Game{players: 30, lastMarblePoints: 7_000_000}
+	for i := 0; i < b.N; i++ {
+		play(&game)
 	}
-	a = listToIntArray(circle)
-	if a[0] != 0 || a[1] != 2 || a[2] != 1 || a[3] != 3 {
-		t.Errorf("Circle should be 0, 2, 1, 3 but is %v.", a)
+}
+
+func TestRules(t *testing.T) {
+	game := Game{players: 9, lastMarblePoints: 25}
+	if score, err := playWithRules(&game, DefaultRules(), nil); err != nil || score != 32 {
+		t.Errorf("Expected high score 32 with the default rules, got %v.", score)
+	}
+	// Without special marbles nobody scores.
+	rules := DefaultRules()
+	rules.Modulus = 0
+	if score, err := playWithRules(&game, rules, nil); err != nil || score != 0 {
+		t.Errorf("Expected high score 0 without special marbles, got %v.", score)
+	}
+	// Keeping only the marble itself scores 23 for player 5.
+	rules = DefaultRules()
+	rules.Score = func(marble, removed int) int { return marble }
+	if score, err := playWithRules(&game, rules, nil); err != nil || score != 23 {
+		t.Errorf("Expected high score 23, got %v.", score)
+	}
+	// Removing the current marble itself takes 22 and makes 19 current.
+	rules = DefaultRules()
+	rules.RemovalOffset = 0
+	if score, err := playWithRules(&Game{players: 9, lastMarblePoints: 23}, rules, nil); err != nil || score != 45 {
+		t.Errorf("Expected high score 45, got %v.", score)
+	}
+	// A lone player losing points has a negative high score.
+	rules = DefaultRules()
+	rules.Score = func(marble, removed int) int { return removed - marble }
+	if score, err := playWithRules(&Game{players: 1, lastMarblePoints: 23}, rules, nil); err != nil || score != -14 {
+		t.Errorf("Expected high score -14, got %v.", score)
 	}
 }
 
-func TestHandleMultipleOf23(t *testing.T) {
-	a1 := []int{0, 16, 8, 17, 4, 18, 9, 19, 2, 20, 10, 21, 5, 22, 11, 1, 12, 6, 13, 3, 14, 7, 15}
-	circle := intArrayToList(a1)
-	i1 := circle.Front()
-	for i := 0; i < 13; i++ {
-		i1 = i1.Next()
-	}
-	score, i2 := handleMultipleOf23(circle, i1, 23)
-	if score != 23+9 {
-		t.Errorf("Expected score 32 but got %v.", score)
-	}
-	element6 := elementOfIndex(circle, 6)
-	if i2 != element6 {
-		t.Errorf("Expected index 6 but got %v.", i2)
-	}
-	a2 := []int{0, 16, 8, 17, 4, 18, 19, 2, 20, 10, 21, 5, 22, 11, 1, 12, 6, 13, 3, 14, 7, 15}
-	expectedCircle := intArrayToList(a2)
-	if circle.Len() != expectedCircle.Len() {
-		t.Errorf("Expected new circle length %v but got %v", expectedCircle.Len(), circle.Len())
-	}
-	for i := 0; i < expectedCircle.Len(); i++ {
-		if a2[i] != elementOfIndex(expectedCircle, i).Value.(int) {
-			t.Errorf("Circle value %v was %v but expected %v.", i, a2[i],
-				elementOfIndex(expectedCircle, 6).Value)
+func TestInvalidRules(t *testing.T) {
+	game := Game{players: 9, lastMarblePoints: 25}
+	for _, modulus := range []int{1, -23} {
+		rules := DefaultRules()
+		rules.Modulus = modulus
+		if _, err := playWithRules(&game, rules, nil); err == nil {
+			t.Errorf("Expected an error for modulus %v.", modulus)
 		}
 	}
+	if _, err := playWithRules(&Game{players: 0, lastMarblePoints: 25}, DefaultRules(), nil); err == nil {
+		t.Error("Expected an error without players.")
+	}
+	rules := DefaultRules()
+	rules.Score = nil
+	if _, err := playWithRules(&game, rules, nil); err == nil {
+		t.Error("Expected an error without a scoring function.")
+	}
+	// Every other modulus leaves at least one marble in the circle.
+	rules = DefaultRules()
+	rules.Modulus = 2
+	if _, err := playWithRules(&game, rules, nil); err != nil {
+		t.Error(err)
+	}
 }
 
-func TestPlayGames(t *testing.T) {
-	games := loadData("test_input.txt", true)
-	var score int
-	for _, game := range games {
-		score = play(&game)
-		if game.highScore != score {
-			t.Errorf("Game %v expected highscore = %v, but got %v.", game, game.highScore, score)
-		}
+func TestTimeline(t *testing.T) {
+	timeline := NewTimeline(3)
+	timeline.Record(23, 1, []int{0, 30, 0})
+	timeline.Record(46, 0, []int{30, 30, 0})
+	timeline.Record(69, 2, []int{30, 30, 80})
+	timeline.Record(92, 1, []int{30, 60, 80})
+
+	leads := timeline.Leads()
+	if len(leads) != 2 || leads[0].Leader != 1 || leads[1].Leader != 2 || leads[1].Marble != 69 {
+		t.Errorf("Expected player 2 then player 3 to lead, got %v.", leads)
+	}
+
+	var b strings.Builder
+	if err := timeline.WriteCSV(&b); err != nil {
+		t.Fatal(err)
+	}
+	expected := "marble,player,leader,player_1,player_2,player_3\n" +
+		"23,2,2,0,30,0\n" +
+		"46,1,2,30,30,0\n" +
+		"69,3,3,30,30,80\n" +
+		"92,2,3,30,60,80\n"
+	if b.String() != expected {
+		t.Errorf("Expected\n%v\ngot\n%v", expected, b.String())
+	}
+}
+
+func TestTimelineOfGame(t *testing.T) {
+	timeline := NewTimeline(9)
+	if _, err := playWithRules(&Game{players: 9, lastMarblePoints: 25}, DefaultRules(), timeline); err != nil {
+		t.Fatal(err)
+	}
+	if len(timeline.Turns) != 1 || timeline.Turns[0].Player != 4 || timeline.Turns[0].Scores[4] != 32 {
+		t.Errorf("Expected a single turn scoring 32 for player 5, got %v.", timeline.Turns)
+	}
+}
+
+func TestTimelineNegativeScores(t *testing.T) {
+	rules := DefaultRules()
+	rules.Score = func(marble, removed int) int { return removed - marble }
+	timeline := NewTimeline(3)
+	if _, err := playWithRules(&Game{players: 3, lastMarblePoints: 46}, rules, timeline); err != nil {
+		t.Fatal(err)
+	}
+	// Marble 23 costs player 2 points, leaving player 1 ahead on the tie
+	// at 0. Marble 46 costs player 1 points, so player 3 takes the lead
+	// without scoring.
+	if len(timeline.Turns) != 2 {
+		t.Fatalf("Expected 2 scoring turns, got %v.", timeline.Turns)
+	}
+	if turn := timeline.Turns[1]; turn.Player != 0 || turn.Scores[0] >= 0 || turn.Leader != 2 {
+		t.Errorf("Expected player 3 to lead after player 1 lost points, got %v.", turn)
+	}
+	if turn := timeline.Turns[0]; turn.Player != 1 || turn.Scores[1] != 9-23 {
+		t.Errorf("Expected player 2 to score -14 for marble 23, got %v.", turn)
+	}
+	if leader := timeline.Turns[0].Leader; leader != 0 {
+		t.Errorf("Expected player 1 to lead after marble 23, got player %v.", leader+1)
 	}
 }
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Turn is a scoring turn: the marble kept, the player who kept it and the
+// scores of all players afterwards.
+type Turn struct {
+	Marble int
+	Player int
+	Scores []int
+	Leader int
+}
+
+// Timeline records every scoring turn of a game.
+type Timeline struct {
+	players int
+	Turns   []Turn
+}
+
+func NewTimeline(players int) *Timeline {
+	return &Timeline{players: players}
+}
+
+// Record stores the scores after a player kept a marble. The leader is the
+// player with the highest score, which may be negative with other rules. On
+// a tie the previous leader keeps the lead.
+func (t *Timeline) Record(marble, player int, scores []int) {
+	leader := -1
+	if len(t.Turns) > 0 {
+		leader = t.Turns[len(t.Turns)-1].Leader
+	}
+	for p, score := range scores {
+		if leader < 0 || score > scores[leader] {
+			leader = p
+		}
+	}
+	t.Turns = append(t.Turns, Turn{
+		Marble: marble,
+		Player: player,
+		Scores: append([]int(nil), scores...),
+		Leader: leader,
+	})
+}
+
+// Leads returns the turns where the lead changed hands, starting with the
+// first scoring turn.
+func (t *Timeline) Leads() []Turn {
+	leads := make([]Turn, 0)
+	for i, turn := range t.Turns {
+		if i == 0 || turn.Leader != t.Turns[i-1].Leader {
+			leads = append(leads, turn)
+		}
+	}
+	return leads
+}
+
+// PrintLeads writes who led from which marble on, numbering players from 1.
+func (t *Timeline) PrintLeads(w io.Writer) {
+	for _, turn := range t.Leads() {
+		fmt.Fprintf(w, "marble %7v: player %3v leads with %v\n",
+			turn.Marble, turn.Leader+1, turn.Scores[turn.Leader])
+	}
+}
+
+// WriteCSV writes one row per scoring turn with the marble, the scoring
+// player, the leader and every player's score.
+func (t *Timeline) WriteCSV(w io.Writer) error {
+	c := csv.NewWriter(w)
+	header := []string{"marble", "player", "leader"}
+	for p := 1; p <= t.players; p++ {
+		header = append(header, "player_"+strconv.Itoa(p))
+	}
+	if err := c.Write(header); err != nil {
+		return err
+	}
+	for _, turn := range t.Turns {
+		row := []string{strconv.Itoa(turn.Marble), strconv.Itoa(turn.Player + 1), strconv.Itoa(turn.Leader + 1)}
+		for _, score := range turn.Scores {
+			row = append(row, strconv.Itoa(score))
+		}
+		if err := c.Write(row); err != nil {
+			return err
+		}
+	}
+	c.Flush()
+	return c.Error()
+}